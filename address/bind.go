@@ -0,0 +1,115 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package address
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"go.osspkg.com/errors"
+)
+
+var (
+	ErrResolveBind = errors.New("resolve bind address")
+)
+
+const (
+	// IPv4Any is the host of a listen address bound to all IPv4 addresses, e.g. [ipv4-any]:8080.
+	IPv4Any = "ipv4-any"
+	// IPv6Any is the host of a listen address bound to all IPv6 addresses, e.g. [ipv6-any]:8080.
+	IPv6Any = "ipv6-any"
+)
+
+// IsBindHost reports whether the host of the listen address is a wildcard
+// of a family or a name of a network interface.
+func IsBindHost(address string) bool {
+	host := bindHost(address)
+	if host == IPv4Any || host == IPv6Any {
+		return true
+	}
+	if len(host) == 0 || IsValidIP(host) {
+		return false
+	}
+	_, err := net.InterfaceByName(host)
+	return err == nil
+}
+
+// ResolveBind expands the host of a listen address for the network: [ipv4-any] and
+// [ipv6-any] become the wildcard of the family and an interface name, e.g. eth0:8080,
+// becomes the addresses of the interface, IPv4 first. The family is taken from
+// the network suffix: tcp4 and udp4 get IPv4 only, tcp6 and udp6 get IPv6 only.
+// Other addresses are returned as is.
+func ResolveBind(network, address string) ([]string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host, port = bindHost(address), ""
+	}
+	v4, v6 := !strings.HasSuffix(network, "6"), !strings.HasSuffix(network, "4")
+
+	switch host {
+	case IPv4Any:
+		if !v4 {
+			return nil, fmt.Errorf("%w: %s for %s network", ErrResolveBind, IPv4Any, network)
+		}
+		return []string{joinBind("0.0.0.0", port)}, nil
+	case IPv6Any:
+		if !v6 {
+			return nil, fmt.Errorf("%w: %s for %s network", ErrResolveBind, IPv6Any, network)
+		}
+		return []string{joinBind("::", port)}, nil
+	}
+	if len(host) == 0 || IsValidIP(host) {
+		return []string{address}, nil
+	}
+	ifi, err := net.InterfaceByName(host)
+	if err != nil {
+		// not an interface, the host is resolved by the caller
+		return []string{address}, nil
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrResolveBind, err)
+	}
+
+	var ip4, ip6, local []string
+	for _, a := range addrs {
+		ipn, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		switch ip := ipn.IP; {
+		case ip.To4() != nil:
+			if v4 {
+				ip4 = append(ip4, joinBind(ip.String(), port))
+			}
+		case !v6:
+		case ip.IsLinkLocalUnicast():
+			local = append(local, joinBind(ip.String()+"%"+ifi.Name, port))
+		default:
+			ip6 = append(ip6, joinBind(ip.String(), port))
+		}
+	}
+	result := append(append(ip4, ip6...), local...)
+	if len(result) == 0 {
+		return nil, fmt.Errorf("%w: interface %s has no address for %s network", ErrResolveBind, host, network)
+	}
+	return result, nil
+}
+
+func bindHost(address string) string {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(address, "["), "]")
+}
+
+func joinBind(host, port string) string {
+	if len(port) == 0 {
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
@@ -21,7 +21,10 @@ func RandomPort(host string) (string, error) {
 	if strings.Contains(host, ":") {
 		network = "tcp6"
 	}
+	return randomPort(network, host)
+}
 
+func randomPort(network, host string) (string, error) {
 	host = net.JoinHostPort(host, "0")
 	addr, err := net.ResolveTCPAddr(network, host)
 	if err != nil {
@@ -42,13 +45,33 @@ func RandomPort(host string) (string, error) {
 	return v, nil
 }
 
+// ResolveIPPort returns host:port of the address, the forms of ResolveBind take
+// their first address and a hostname takes its first IPv4 if it has one.
 func ResolveIPPort(address string) string {
+	return ResolveNetIPPort("", address)
+}
+
+// ResolveNetIPPort is ResolveIPPort for the family of the network: tcp4 and udp4
+// take an IPv4 of a hostname, tcp6 and udp6 take an IPv6 and the IPv6 defaults,
+// a hostname without an address of the family is returned as is.
+func ResolveNetIPPort(network, address string) string {
 	var (
 		host string
 		port string
 	)
+	v6 := strings.HasSuffix(network, "6")
+
+	if list, err := ResolveBind(network, address); err == nil && list[0] != address {
+		if _, p, e := net.SplitHostPort(list[0]); e == nil && len(p) > 0 {
+			return list[0]
+		}
+		address = list[0]
+	}
 
 	switch true {
+	case len(address) == 0 && v6:
+		host = "::1"
+
 	case len(address) == 0:
 		host = "127.0.0.1"
 
@@ -89,16 +112,28 @@ func ResolveIPPort(address string) string {
 		return host
 	}
 
+	if len(host) == 0 && v6 {
+		host = "::"
+	}
 	if len(host) == 0 {
 		host = "0.0.0.0"
 	}
 
 	if ips, err := net.LookupIP(host); err == nil && len(ips) > 0 {
-		host = ips[0].String()
+		if ip := familyIP(network, ips); ip != nil {
+			host = ip.String()
+		}
 	}
 
 	if len(port) == 0 || port == ":" {
-		if v, err := RandomPort(host); err == nil {
+		probe := "tcp4"
+		switch {
+		case v6:
+			probe = "tcp6"
+		case !strings.HasSuffix(network, "4") && strings.Contains(host, ":"):
+			probe = "tcp6"
+		}
+		if v, err := randomPort(probe, host); err == nil {
 			return v
 		}
 		port = "8080"
@@ -130,6 +165,30 @@ func FixIPPort(defaultPort string, ips ...string) []string {
 	return result
 }
 
+// familyIP picks the address of the network family, IPv4 is preferred
+// for tcp and udp, nil means the host has no address of the family.
+func familyIP(network string, ips []net.IP) net.IP {
+	v4, v6 := !strings.HasSuffix(network, "6"), !strings.HasSuffix(network, "4")
+	if v4 && v6 {
+		return preferIPv4(ips)
+	}
+	for _, ip := range ips {
+		if (ip.To4() != nil) == v4 {
+			return ip
+		}
+	}
+	return nil
+}
+
+func preferIPv4(ips []net.IP) net.IP {
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip
+		}
+	}
+	return ips[0]
+}
+
 func IsValidIP(ip string) bool {
 	return net.ParseIP(ip) != nil
 }
@@ -73,3 +73,53 @@ func TestUnit_ResolveIPPort(t *testing.T) {
 		})
 	}
 }
+
+func TestUnit_ResolveBind(t *testing.T) {
+	tests := []struct {
+		network string
+		addr    string
+		want    []string
+		err     bool
+	}{
+		{network: "tcp", addr: "[ipv4-any]:8080", want: []string{"0.0.0.0:8080"}},
+		{network: "tcp", addr: "[ipv6-any]:8080", want: []string{"[::]:8080"}},
+		{network: "tcp6", addr: "[ipv4-any]:8080", err: true},
+		{network: "udp4", addr: "[ipv6-any]:8080", err: true},
+		{network: "tcp4", addr: "lo:8080", want: []string{"127.0.0.1:8080"}},
+		{network: "tcp", addr: "1.1.1.1:123", want: []string{"1.1.1.1:123"}},
+		{network: "tcp", addr: "localhost:123", want: []string{"localhost:123"}},
+		{network: "tcp6", addr: "localhost:123", want: []string{"localhost:123"}},
+		{network: "udp6", addr: "localhost:123", want: []string{"localhost:123"}},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("[Case%d]=>'%s %s'", i, tt.network, tt.addr), func(t *testing.T) {
+			got, err := address.ResolveBind(tt.network, tt.addr)
+			if tt.err {
+				casecheck.Error(t, err)
+				return
+			}
+			casecheck.NoError(t, err)
+			casecheck.Equal(t, tt.want, got)
+		})
+	}
+
+	casecheck.True(t, address.IsBindHost("lo:8080"))
+	casecheck.True(t, address.IsBindHost("[ipv6-any]:8080"))
+	casecheck.False(t, address.IsBindHost("127.0.0.1:8080"))
+	casecheck.Equal(t, "127.0.0.1:8080", address.ResolveIPPort("lo:8080"))
+
+	// a hostname takes an address of the network family, the name is kept without one
+	for _, tt := range []struct{ network, want string }{
+		{network: "tcp", want: `^(127\.0\.0\.1|\[::1\]):123$`},
+		{network: "tcp4", want: `^127\.0\.0\.1:123$`},
+		{network: "udp4", want: `^127\.0\.0\.1:123$`},
+		{network: "tcp6", want: `^(\[::1\]|localhost):123$`},
+		{network: "udp6", want: `^(\[::1\]|localhost):123$`},
+	} {
+		got := address.ResolveNetIPPort(tt.network, "localhost:123")
+		ok, err := regexp.MatchString(tt.want, got)
+		casecheck.NoError(t, err)
+		casecheck.True(t, ok, tt.network, got)
+	}
+	casecheck.Equal(t, "[::]:123", address.ResolveNetIPPort("tcp6", ":123"))
+}
@@ -0,0 +1,163 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package address
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go.osspkg.com/errors"
+)
+
+var (
+	ErrInvalidEndpoint = errors.New("invalid endpoint")
+)
+
+const (
+	SchemeTCP  = "tcp"
+	SchemeTLS  = "tls"
+	SchemeUDP  = "udp"
+	SchemeQUIC = "quic"
+	SchemeUNIX = "unix"
+)
+
+// Endpoint is a parsed URL-style address:
+//
+//	tcp://host:port, tls://host:port, udp://host:port, quic://host:port,
+//	unix:///path/to.sock, unix:@abstract
+//
+// The query is kept in Options, e.g. tcp://:8080?reuse_port=1.
+type Endpoint struct {
+	// Network is tcp, udp, quic or unix, the tls scheme is tcp with TLS set.
+	Network string
+	TLS     bool
+	Host    string
+	Port    uint16
+	// Path is the unix socket file, an abstract socket starts with @.
+	Path    string
+	Options url.Values
+}
+
+// IsEndpoint reports whether s has an endpoint scheme, a plain host:port does not.
+func IsEndpoint(s string) bool {
+	for _, scheme := range []string{SchemeTCP, SchemeTLS, SchemeUDP, SchemeQUIC} {
+		if strings.HasPrefix(s, scheme+"://") {
+			return true
+		}
+	}
+	return strings.HasPrefix(s, SchemeUNIX+":")
+}
+
+// ParseEndpoint parses s strictly: an unknown scheme, a missing port, an invalid
+// host or an empty unix path is an error, nothing is replaced by a default.
+func ParseEndpoint(s string) (Endpoint, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return Endpoint{}, fmt.Errorf("%w: %w", ErrInvalidEndpoint, err)
+	}
+	e := Endpoint{Network: u.Scheme, Options: u.Query()}
+
+	switch u.Scheme {
+	case SchemeUNIX:
+		return e, e.parseUnix(u)
+	case SchemeTLS:
+		e.Network, e.TLS = SchemeTCP, true
+	case SchemeTCP, SchemeUDP, SchemeQUIC:
+	case "":
+		return Endpoint{}, fmt.Errorf("%w: scheme is empty in %q", ErrInvalidEndpoint, s)
+	default:
+		return Endpoint{}, fmt.Errorf("%w: unknown scheme %q", ErrInvalidEndpoint, u.Scheme)
+	}
+
+	if len(u.Opaque) > 0 || u.User != nil || (len(u.Path) > 0 && u.Path != "/") || len(u.Fragment) > 0 {
+		return Endpoint{}, fmt.Errorf("%w: %s endpoint must be %s://host:port", ErrInvalidEndpoint, u.Scheme, u.Scheme)
+	}
+	if strings.Contains(u.Hostname(), ":") && !strings.HasPrefix(u.Host, "[") {
+		return Endpoint{}, fmt.Errorf("%w: ipv6 must be in brackets in %q", ErrInvalidEndpoint, s)
+	}
+	if e.Host, err = validHost(u.Hostname()); err != nil {
+		return Endpoint{}, err
+	}
+	port := u.Port()
+	if len(port) == 0 {
+		return Endpoint{}, fmt.Errorf("%w: port is empty in %q", ErrInvalidEndpoint, s)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return Endpoint{}, fmt.Errorf("%w: invalid port %q", ErrInvalidEndpoint, port)
+	}
+	e.Port = uint16(p)
+	return e, nil
+}
+
+func (e *Endpoint) parseUnix(u *url.URL) error {
+	switch {
+	case strings.HasPrefix(u.Opaque, "@"):
+		e.Path = u.Opaque
+	case len(u.Opaque) == 0 && len(u.Host) == 0 && strings.HasPrefix(u.Path, "/"):
+		e.Path = u.Path
+	default:
+		return fmt.Errorf("%w: unix endpoint must be unix:///path or unix:@name", ErrInvalidEndpoint)
+	}
+	if e.Path == "@" || e.Path == "/" {
+		return fmt.Errorf("%w: unix path is empty", ErrInvalidEndpoint)
+	}
+	return nil
+}
+
+// validHost accepts an empty host (all interfaces), an IP or a DNS name.
+func validHost(host string) (string, error) {
+	if len(host) == 0 || IsValidIP(host) {
+		return host, nil
+	}
+	if ip, zone, ok := strings.Cut(host, "%"); ok && len(zone) > 0 && strings.Contains(ip, ":") && IsValidIP(ip) {
+		return host, nil
+	}
+	if strings.Contains(host, ":") {
+		return "", fmt.Errorf("%w: invalid ipv6 %q", ErrInvalidEndpoint, host)
+	}
+	if len(host) > 253 {
+		return "", fmt.Errorf("%w: host is too long", ErrInvalidEndpoint)
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return "", fmt.Errorf("%w: invalid host %q", ErrInvalidEndpoint, host)
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+				return "", fmt.Errorf("%w: invalid host %q", ErrInvalidEndpoint, host)
+			}
+		}
+	}
+	return host, nil
+}
+
+// Address is host:port for the dial and listen functions or the unix path.
+func (e Endpoint) Address() string {
+	if e.Network == SchemeUNIX {
+		return e.Path
+	}
+	return net.JoinHostPort(e.Host, strconv.Itoa(int(e.Port)))
+}
+
+func (e Endpoint) String() string {
+	if e.Network == SchemeUNIX {
+		u := url.URL{Scheme: SchemeUNIX, Path: e.Path, RawQuery: e.Options.Encode()}
+		if strings.HasPrefix(e.Path, "@") {
+			u = url.URL{Scheme: SchemeUNIX, Opaque: e.Path, RawQuery: e.Options.Encode()}
+		}
+		return u.String()
+	}
+	scheme := e.Network
+	if e.TLS {
+		scheme = SchemeTLS
+	}
+	u := url.URL{Scheme: scheme, Host: e.Address(), RawQuery: e.Options.Encode()}
+	return u.String()
+}
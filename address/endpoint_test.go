@@ -0,0 +1,80 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package address_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.osspkg.com/casecheck"
+
+	"go.osspkg.com/network/address"
+)
+
+func TestUnit_ParseEndpoint(t *testing.T) {
+	tests := []struct {
+		in      string
+		network string
+		tls     bool
+		addr    string
+		str     string
+	}{
+		{in: "tcp://127.0.0.1:8080", network: "tcp", addr: "127.0.0.1:8080"},
+		{in: "tcp://:8080", network: "tcp", addr: ":8080"},
+		{in: "tcp://localhost:0", network: "tcp", addr: "localhost:0"},
+		{in: "tls://example.com:443", network: "tcp", tls: true, addr: "example.com:443"},
+		{in: "udp://[::1]:53", network: "udp", addr: "[::1]:53"},
+		{in: "quic://[fe80::1%25eth0]:443", network: "quic", addr: "[fe80::1%eth0]:443"},
+		{in: "tcp://0.0.0.0:80?reuse_port=1", network: "tcp", addr: "0.0.0.0:80"},
+		{in: "unix:///tmp/app.sock", network: "unix", addr: "/tmp/app.sock"},
+		{in: "unix:@app", network: "unix", addr: "@app"},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("[Case%d]=>'%s'", i, tt.in), func(t *testing.T) {
+			e, err := address.ParseEndpoint(tt.in)
+			casecheck.NoError(t, err)
+			casecheck.Equal(t, tt.network, e.Network)
+			casecheck.Equal(t, tt.tls, e.TLS)
+			casecheck.Equal(t, tt.addr, e.Address())
+
+			again, err := address.ParseEndpoint(e.String())
+			casecheck.NoError(t, err, e.String())
+			casecheck.Equal(t, e.Address(), again.Address())
+		})
+	}
+
+	e, err := address.ParseEndpoint("tcp://:80?reuse_port=1")
+	casecheck.NoError(t, err)
+	casecheck.Equal(t, "1", e.Options.Get("reuse_port"))
+}
+
+func TestUnit_ParseEndpointInvalid(t *testing.T) {
+	for i, in := range []string{
+		"",
+		"127.0.0.1:80",
+		"http://127.0.0.1:80",
+		"tcp://127.0.0.1",
+		"tcp://127.0.0.1:",
+		"tcp://127.0.0.1:70000",
+		"tcp://[::zz]:80",
+		"tcp://::1:80",
+		"tcp://bad_host!:80",
+		"tcp://-a.com:80",
+		"tcp://host:80/path",
+		"tcp://user@host:80",
+		"unix://host/path",
+		"unix:",
+		"unix:relative.sock",
+		"unix:@",
+	} {
+		t.Run(fmt.Sprintf("[Case%d]=>'%s'", i, in), func(t *testing.T) {
+			_, err := address.ParseEndpoint(in)
+			casecheck.Error(t, err)
+			casecheck.True(t, errors.Is(err, address.ErrInvalidEndpoint), err)
+		})
+	}
+}
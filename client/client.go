@@ -11,27 +11,35 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"sync"
 
 	"github.com/quic-go/quic-go"
 	"go.osspkg.com/algorithms/control"
 	"go.osspkg.com/errors"
 
 	"go.osspkg.com/network/internal"
+	"go.osspkg.com/network/mux"
 )
 
 type (
 	Client interface {
 		Call(ctx context.Context, handler func(ctx context.Context, w io.Writer, r io.Reader) error) error
+		Close() error
 	}
 
 	_client struct {
 		conf Config
 		tls  *tls.Config
 		sem  control.Semaphore
+		sess mux.Session
+		mux  sync.Mutex
 	}
 )
 
 func New(c Config) (Client, error) {
+	if err := c.endpoint(); err != nil {
+		return nil, fmt.Errorf("parse endpoint: %w", err)
+	}
 	addr, err := c.Resolve()
 	if err != nil {
 		return nil, fmt.Errorf("resolve address: %w", err)
@@ -44,6 +52,21 @@ func New(c Config) (Client, error) {
 		return nil, fmt.Errorf("get tls config: %w", err)
 	}
 
+	if c.Mux != nil {
+		if !internal.IsTCP(c.Network) && c.Network != internal.NetUNIX {
+			return nil, fmt.Errorf("mux is supported only for tcp and unix networks")
+		}
+		if err = c.Mux.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.Socket != nil {
+		if err = c.Socket.Validate(c.Network); err != nil {
+			return nil, err
+		}
+	}
+
 	if c.MaxConns <= 0 {
 		c.MaxConns = 1
 	}
@@ -58,8 +81,8 @@ func New(c Config) (Client, error) {
 }
 
 func (v *_client) conn(ctx context.Context) (internal.Conn, error) {
-	switch v.conf.Network {
-	case internal.NetQUIC:
+	switch {
+	case v.conf.Network == internal.NetQUIC:
 		conn, err := quic.DialAddr(ctx, v.conf.Address, v.tls, &quic.Config{EnableDatagrams: false})
 		if err != nil {
 			return nil, fmt.Errorf("dial quic: %w", err)
@@ -73,13 +96,38 @@ func (v *_client) conn(ctx context.Context) (internal.Conn, error) {
 			return errors.Wrap(stream.Close(), conn.CloseWithError(0, ""))
 		}}, nil
 
-	case internal.NetTCP:
+	case v.conf.Mux != nil:
+		return v.stream(ctx)
+
+	default:
+		return v.dial(ctx)
+	}
+}
+
+func (v *_client) dial(ctx context.Context) (net.Conn, error) {
+	sock := Socket{}
+	if v.conf.Socket != nil {
+		sock = *v.conf.Socket
+	}
+	conn, err := v.dialNet(ctx, sock.dialer())
+	if err != nil {
+		return nil, err
+	}
+	if err = sock.apply(conn); err != nil {
+		return nil, errors.Wrap(fmt.Errorf("set socket options: %w", err), conn.Close())
+	}
+	return conn, nil
+}
+
+func (v *_client) dialNet(ctx context.Context, dial *net.Dialer) (net.Conn, error) {
+	switch v.conf.Network {
+	case internal.NetTCP, internal.NetTCP4, internal.NetTCP6:
 		if v.tls != nil {
-			dial := &tls.Dialer{
-				NetDialer: new(net.Dialer),
+			tlsDial := &tls.Dialer{
+				NetDialer: dial,
 				Config:    v.tls,
 			}
-			conn, err := dial.DialContext(ctx, v.conf.Network, v.conf.Address)
+			conn, err := tlsDial.DialContext(ctx, v.conf.Network, v.conf.Address)
 			if err != nil {
 				return nil, fmt.Errorf("dial tcp tls: %w", err)
 			}
@@ -88,7 +136,6 @@ func (v *_client) conn(ctx context.Context) (internal.Conn, error) {
 		fallthrough
 
 	default:
-		var dial net.Dialer
 		conn, err := dial.DialContext(ctx, v.conf.Network, v.conf.Address)
 		if err != nil {
 			return nil, fmt.Errorf("dial %s: %w", v.conf.Network, err)
@@ -97,6 +144,65 @@ func (v *_client) conn(ctx context.Context) (internal.Conn, error) {
 	}
 }
 
+func (v *_client) session(ctx context.Context) (mux.Session, error) {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+
+	if v.sess != nil && !v.sess.IsClosed() {
+		return v.sess, nil
+	}
+
+	conn, err := v.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := mux.Client(conn, *v.conf.Mux)
+	if err != nil {
+		return nil, errors.Wrap(err, conn.Close())
+	}
+	v.sess = sess
+	return sess, nil
+}
+
+func (v *_client) dropSession(sess mux.Session) {
+	v.mux.Lock()
+	if v.sess == sess {
+		v.sess = nil
+	}
+	v.mux.Unlock()
+
+	sess.Close() // nolint: errcheck
+}
+
+func (v *_client) stream(ctx context.Context) (internal.Conn, error) {
+	for i := 0; ; i++ {
+		sess, err := v.session(ctx)
+		if err != nil {
+			return nil, err
+		}
+		stream, err := sess.OpenStream(ctx)
+		if err == nil {
+			return stream, nil
+		}
+		if i > 0 || !(errors.Is(err, mux.ErrRemoteGoAway) || errors.Is(err, mux.ErrSessionShutdown)) {
+			return nil, fmt.Errorf("open stream mux: %w", err)
+		}
+		v.dropSession(sess)
+	}
+}
+
+func (v *_client) Close() error {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+
+	if v.sess == nil {
+		return nil
+	}
+	err := v.sess.Close()
+	v.sess = nil
+	return err
+}
+
 func (v *_client) Call(ctx context.Context, handler func(ctx context.Context, w io.Writer, r io.Reader) error) (e error) {
 	v.sem.Acquire()
 	defer func() { v.sem.Release() }()
@@ -9,29 +9,75 @@ import (
 	"fmt"
 	"net"
 
+	"go.osspkg.com/network/address"
 	"go.osspkg.com/network/internal"
+	"go.osspkg.com/network/mux"
 )
 
 type Config struct {
-	Network     string
+	Network string
+	// Address is host:port or path of the Network, or an endpoint URL which sets
+	// the network too, its query sets Socket, see address.ParseEndpoint.
 	Address     string
 	Certificate *Certificate
 	MaxConns    uint64
+	// Mux opens every call as a stream on one shared tcp or unix connection.
+	Mux *mux.Config
+	// Socket tunes the dialed socket: buffers, keepalive and tcp options.
+	Socket *Socket
+}
+
+// endpoint applies an endpoint URL in Address and its query to Socket, a tls
+// endpoint without a certificate verifies the server by the system CA.
+func (c *Config) endpoint() error {
+	if !address.IsEndpoint(c.Address) {
+		return nil
+	}
+	e, err := address.ParseEndpoint(c.Address)
+	if err != nil {
+		return err
+	}
+	if len(c.Network) > 0 && c.Network != e.Network {
+		return fmt.Errorf("network %s conflicts with endpoint %s", c.Network, c.Address)
+	}
+	if e.TLS && c.Certificate == nil {
+		c.Certificate = &Certificate{SystemCA: true}
+	}
+	if len(e.Options) > 0 {
+		// the query is set to a copy, the socket of the caller is not changed
+		var socket Socket
+		if c.Socket != nil {
+			socket = *c.Socket
+		}
+		for key, values := range e.Options {
+			if err = socket.SetOption(key, values[len(values)-1]); err != nil {
+				return fmt.Errorf("endpoint %s: %w", c.Address, err)
+			}
+		}
+		c.Socket = &socket
+	}
+	c.Network, c.Address = e.Network, e.Address()
+	return nil
 }
 
 func (c Config) Resolve() (addr fmt.Stringer, err error) {
+	if err = c.endpoint(); err != nil {
+		return nil, err
+	}
 	if err := internal.IsPassableNetwork(c.Network); err != nil {
 		return nil, err
 	}
 
 	switch c.Network {
-	case internal.NetTCP:
-		return net.ResolveTCPAddr(internal.NetTCP, c.Address)
-	case internal.NetUDP, internal.NetQUIC:
+	case internal.NetTCP, internal.NetTCP4, internal.NetTCP6:
+		return net.ResolveTCPAddr(c.Network, c.Address)
+	case internal.NetUDP, internal.NetUDP4, internal.NetUDP6:
+		return net.ResolveUDPAddr(c.Network, c.Address)
+	case internal.NetQUIC:
 		return net.ResolveUDPAddr(internal.NetUDP, c.Address)
 	case internal.NetUNIX:
 		return net.ResolveUnixAddr(internal.NetUNIX, c.Address)
 	default:
-		return nil, fmt.Errorf("invalid network name, use: tcp, udp, unix, quic")
+		return nil, fmt.Errorf("invalid network name, use: tcp, tcp4, tcp6, udp, udp4, udp6, unix, quic")
 	}
 }
@@ -0,0 +1,38 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package client_test
+
+import (
+	"errors"
+	"testing"
+
+	"go.osspkg.com/casecheck"
+
+	"go.osspkg.com/network/client"
+	"go.osspkg.com/network/internal"
+)
+
+func TestUnit_ConfigEndpointOptions(t *testing.T) {
+	socket := &client.Socket{}
+	addr, err := client.Config{
+		Address: "tcp://127.0.0.1:8080?keepalive_count=3&no_delay=false",
+		Socket:  socket,
+	}.Resolve()
+	casecheck.NoError(t, err)
+	casecheck.Equal(t, "127.0.0.1:8080", addr.String())
+	casecheck.Equal(t, client.Socket{}, *socket)
+
+	_, err = client.Config{Address: "tcp://127.0.0.1:8080?reuse_port=1"}.Resolve()
+	casecheck.True(t, errors.Is(err, internal.ErrUnknownOption), err)
+
+	_, err = client.Config{Address: "tcp://127.0.0.1:8080?keepalive_count=many"}.Resolve()
+	casecheck.Error(t, err)
+	casecheck.False(t, errors.Is(err, internal.ErrUnknownOption), err)
+
+	// the query is checked as Socket for the network of the endpoint
+	_, err = client.New(client.Config{Address: "udp://127.0.0.1:8080?no_delay=true"})
+	casecheck.Error(t, err)
+}
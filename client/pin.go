@@ -0,0 +1,128 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"go.osspkg.com/errors"
+)
+
+var (
+	ErrInvalidPin   = errors.New("invalid certificate pin")
+	ErrPinMismatch  = errors.New("peer certificate does not match any pin")
+	ErrPinNoPeer    = errors.New("peer did not present a certificate")
+	ErrPinConflicts = errors.New("pin_verify_chain conflicts with insecure_skip_verify")
+)
+
+type pins struct {
+	spki   [][]byte
+	leaf   [][]byte
+	verify bool
+}
+
+func (c *Certificate) pins() (*pins, error) {
+	if len(c.PinSHA256) == 0 && len(c.CertSHA256) == 0 {
+		return nil, nil
+	}
+	if c.PinVerifyChain && c.InsecureSkipVerify {
+		return nil, ErrPinConflicts
+	}
+
+	p := &pins{
+		spki:   make([][]byte, 0, len(c.PinSHA256)),
+		leaf:   make([][]byte, 0, len(c.CertSHA256)),
+		verify: c.PinVerifyChain,
+	}
+	for _, v := range c.PinSHA256 {
+		b, err := decodePin(v)
+		if err != nil {
+			return nil, err
+		}
+		p.spki = append(p.spki, b)
+	}
+	for _, v := range c.CertSHA256 {
+		b, err := decodePin(v)
+		if err != nil {
+			return nil, err
+		}
+		p.leaf = append(p.leaf, b)
+	}
+	return p, nil
+}
+
+// decodePin accepts `sha256/<base64>`, plain base64 or hex (colons allowed) SHA-256 digests.
+func decodePin(v string) ([]byte, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(v), "sha256/")
+
+	if h := strings.ReplaceAll(s, ":", ""); len(h) == sha256.Size*2 {
+		if b, err := hex.DecodeString(h); err == nil {
+			return b, nil
+		}
+	}
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil && len(b) == sha256.Size {
+		return b, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrInvalidPin, v)
+}
+
+func (p *pins) apply(conf *tls.Config) {
+	if !p.verify {
+		// The chain is not validated, only the pins are trusted.
+		conf.InsecureSkipVerify = true
+	}
+	conf.VerifyConnection = p.verifyConnection
+}
+
+func (p *pins) verifyConnection(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return ErrPinNoPeer
+	}
+
+	leaf := cs.PeerCertificates[0]
+	if p.matchLeaf(leaf) || p.matchSPKI(leaf) {
+		return nil
+	}
+
+	// Intermediates and roots can be pinned only when the chain was verified.
+	if p.verify {
+		for _, chain := range cs.VerifiedChains {
+			for _, cert := range chain {
+				if p.matchSPKI(cert) {
+					return nil
+				}
+			}
+		}
+	}
+
+	return ErrPinMismatch
+}
+
+func (p *pins) matchLeaf(cert *x509.Certificate) bool {
+	sum := sha256.Sum256(cert.Raw)
+	return containsDigest(p.leaf, sum[:])
+}
+
+func (p *pins) matchSPKI(cert *x509.Certificate) bool {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return containsDigest(p.spki, sum[:])
+}
+
+func containsDigest(list [][]byte, sum []byte) bool {
+	for _, b := range list {
+		if bytes.Equal(b, sum) {
+			return true
+		}
+	}
+	return false
+}
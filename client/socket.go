@@ -0,0 +1,111 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package client
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+
+	"go.osspkg.com/network/internal"
+)
+
+// Socket tunes the dialed socket, it mirrors listen.Socket for the client side.
+// The options are set on Linux, on other systems a set field fails the dial.
+type Socket struct {
+	// ReadBuffer and WriteBuffer set SO_RCVBUF and SO_SNDBUF before connect.
+	ReadBuffer  int
+	WriteBuffer int
+	// NoDelay sets TCP_NODELAY, the Go runtime enables it by default.
+	NoDelay *bool
+	// FastOpen sets TCP_FASTOPEN_CONNECT, the first write goes in the SYN.
+	FastOpen bool
+	// KeepAliveIdle, KeepAliveInterval and KeepAliveCount tune the keepalive
+	// probes, see net.KeepAliveConfig for the defaults.
+	KeepAliveIdle     time.Duration
+	KeepAliveInterval time.Duration
+	KeepAliveCount    int
+	// DisableKeepAlive turns the keepalive probes off.
+	DisableKeepAlive bool
+}
+
+// Validate checks the values and that the options fit the network.
+func (s Socket) Validate(network string) error {
+	switch {
+	case s.ReadBuffer < 0 || s.WriteBuffer < 0:
+		return fmt.Errorf("socket buffer size must be positive")
+	case s.KeepAliveIdle < 0 || s.KeepAliveInterval < 0 || s.KeepAliveCount < 0:
+		return fmt.Errorf("socket keepalive must be positive")
+	case s.DisableKeepAlive && s.keepAlive():
+		return fmt.Errorf("socket keepalive is disabled and tuned at the same time")
+	case network == internal.NetQUIC:
+		return fmt.Errorf("socket options are not supported for quic network")
+	case !internal.IsTCP(network) && (s.NoDelay != nil || s.FastOpen || s.keepAlive() || s.DisableKeepAlive):
+		return fmt.Errorf("socket tcp options are supported only for tcp networks")
+	}
+	return nil
+}
+
+// SetOption sets the field by the name of listen.Socket, e.g. from the query of an endpoint.
+func (s *Socket) SetOption(key, value string) error {
+	return internal.SetOption(map[string]any{
+		"read_buffer":        &s.ReadBuffer,
+		"write_buffer":       &s.WriteBuffer,
+		"no_delay":           &s.NoDelay,
+		"fast_open":          &s.FastOpen,
+		"keepalive_idle":     &s.KeepAliveIdle,
+		"keepalive_interval": &s.KeepAliveInterval,
+		"keepalive_count":    &s.KeepAliveCount,
+		"disable_keepalive":  &s.DisableKeepAlive,
+	}, key, value)
+}
+
+func (s Socket) keepAlive() bool {
+	return s.KeepAliveIdle > 0 || s.KeepAliveInterval > 0 || s.KeepAliveCount > 0
+}
+
+// dialer sets the options which are applied before connect.
+func (s Socket) dialer() *net.Dialer {
+	d := &net.Dialer{}
+	switch {
+	case s.DisableKeepAlive:
+		d.KeepAlive = -1
+	case s.keepAlive():
+		d.KeepAliveConfig = net.KeepAliveConfig{
+			Enable:   true,
+			Idle:     s.KeepAliveIdle,
+			Interval: s.KeepAliveInterval,
+			Count:    s.KeepAliveCount,
+		}
+	}
+
+	var sock []internal.SockOpt
+	if s.ReadBuffer > 0 || s.WriteBuffer > 0 {
+		sock = append(sock, internal.SockBuffers(s.ReadBuffer, s.WriteBuffer))
+	}
+	if s.FastOpen {
+		sock = append(sock, internal.SockFastOpenConnect())
+	}
+	if len(sock) > 0 {
+		d.Control = internal.Control(nil, sock...)
+	}
+	return d
+}
+
+// apply sets the options which the Go runtime overrides on connect.
+func (s Socket) apply(conn net.Conn) error {
+	if s.NoDelay == nil {
+		return nil
+	}
+	if tc, ok := conn.(*tls.Conn); ok {
+		conn = tc.NetConn()
+	}
+	if tc, ok := conn.(*net.TCPConn); ok {
+		return tc.SetNoDelay(*s.NoDelay)
+	}
+	return nil
+}
@@ -8,42 +8,180 @@ package client
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"fmt"
 	"net"
 	"os"
+	"path/filepath"
+	"strings"
+
+	"go.osspkg.com/errors"
 
 	"go.osspkg.com/network/internal"
 )
 
+var (
+	ErrReadCA      = internal.ErrReadCA
+	ErrInvalidCA   = internal.ErrInvalidCA
+	ErrSystemCA    = errors.New("load system ca pool")
+	ErrLoadKeyPair = errors.New("load client key pair")
+	ErrEmptyCADir  = errors.New("ca directory has no certificates")
+
+	caFileExtension = []string{".pem", ".crt", ".cer"}
+)
+
+// Provider fetches PEM or PKCS#12 data by name, e.g. from a secret store,
+// for the *Secret fields of a Certificate.
+type Provider = internal.CertProvider
+
 type Certificate struct {
-	CAFile             string `yaml:"ca_file"`
-	CertFile           string `yaml:"cert_file"`
-	KeyFile            string `yaml:"key_file"`
-	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+	CAFile             string   `yaml:"ca_file"`
+	CAFiles            []string `yaml:"ca_files,omitempty"`
+	CAPEM              string   `yaml:"ca_pem,omitempty"`
+	CASecret           string   `yaml:"ca_secret,omitempty"`
+	SystemCA           bool     `yaml:"system_ca,omitempty"`
+	CertFile           string   `yaml:"cert_file"`
+	KeyFile            string   `yaml:"key_file"`
+	CertPEM            string   `yaml:"cert_pem,omitempty"`
+	KeyPEM             string   `yaml:"key_pem,omitempty"`
+	CertSecret         string   `yaml:"cert_secret,omitempty"`
+	KeySecret          string   `yaml:"key_secret,omitempty"`
+	PKCS12File         string   `yaml:"pkcs12_file,omitempty"`
+	PKCS12Secret       string   `yaml:"pkcs12_secret,omitempty"`
+	Passphrase         string   `yaml:"passphrase,omitempty"`
+	InsecureSkipVerify bool     `yaml:"insecure_skip_verify"`
+	PinSHA256          []string `yaml:"pin_sha256,omitempty"`
+	CertSHA256         []string `yaml:"cert_sha256,omitempty"`
+	PinVerifyChain     bool     `yaml:"pin_verify_chain,omitempty"`
+	CAData             []byte   `yaml:"-"`
+	CertData           []byte   `yaml:"-"`
+	KeyData            []byte   `yaml:"-"`
+	PKCS12Data         []byte   `yaml:"-"`
+	Provider           Provider `yaml:"-"`
 }
 
-func (c *Certificate) parse() (cert tls.Certificate, ca *x509.CertPool, err error) {
-	if len(c.CertFile) > 0 || len(c.KeyFile) > 0 {
-		cert, err = tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
-		if err != nil {
-			return
-		}
+func (c *Certificate) keyPair() internal.KeyPairSource {
+	return internal.KeyPairSource{
+		Cert:       internal.CertSource{File: c.CertFile, PEM: c.CertPEM, Data: c.CertData, Secret: c.CertSecret},
+		Key:        internal.CertSource{File: c.KeyFile, PEM: c.KeyPEM, Data: c.KeyData, Secret: c.KeySecret},
+		PKCS12:     internal.CertSource{File: c.PKCS12File, Data: c.PKCS12Data, Secret: c.PKCS12Secret},
+		Passphrase: c.Passphrase,
 	}
+}
+
+func (c *Certificate) ca() internal.CertSource {
+	return internal.CertSource{PEM: c.CAPEM, Data: c.CAData, Secret: c.CASecret}
+}
+
+func (c *Certificate) caPaths() []string {
+	paths := make([]string, 0, len(c.CAFiles)+1)
 	if len(c.CAFile) > 0 {
+		paths = append(paths, c.CAFile)
+	}
+	for _, path := range c.CAFiles {
+		if len(path) > 0 {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+func (c *Certificate) parse() (cert *tls.Certificate, ca *x509.CertPool, err error) {
+	if pair := c.keyPair(); !pair.IsEmpty() {
+		v, e := pair.Load(c.Provider)
+		if e != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrLoadKeyPair, e)
+		}
+		cert = &v
+	}
+
+	paths, inline := c.caPaths(), c.ca()
+	if len(paths) == 0 && inline.IsEmpty() {
+		return
+	}
+
+	if c.SystemCA {
+		if ca, err = x509.SystemCertPool(); err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrSystemCA, err)
+		}
+	} else {
 		ca = x509.NewCertPool()
-		if caCert, e := os.ReadFile(c.CAFile); e == nil {
-			ca.AppendCertsFromPEM(caCert)
+	}
+
+	for _, path := range paths {
+		if err = appendCA(ca, path); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if !inline.IsEmpty() {
+		b, e := inline.Load(c.Provider)
+		if e != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrReadCA, e)
+		}
+		if !ca.AppendCertsFromPEM(b) {
+			return nil, nil, fmt.Errorf("%w: inline", ErrInvalidCA)
 		}
 	}
 	return
 }
 
+func appendCA(pool *x509.CertPool, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrReadCA, path, err)
+	}
+	if !info.IsDir() {
+		return appendCAFile(pool, path)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrReadCA, path, err)
+	}
+	count := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !hasCAExtension(entry.Name()) {
+			continue
+		}
+		if err = appendCAFile(pool, filepath.Join(path, entry.Name())); err != nil {
+			return err
+		}
+		count++
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: %s", ErrEmptyCADir, path)
+	}
+	return nil
+}
+
+func appendCAFile(pool *x509.CertPool, filename string) error {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrReadCA, filename, err)
+	}
+	if !pool.AppendCertsFromPEM(b) {
+		return fmt.Errorf("%w: %s", ErrInvalidCA, filename)
+	}
+	return nil
+}
+
+func hasCAExtension(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, v := range caFileExtension {
+		if v == ext {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Certificate) Config(address, network string) (*tls.Config, error) {
 	if c == nil {
 		return nil, nil
 	}
 
 	switch network {
-	case internal.NetUNIX, internal.NetUDP:
+	case internal.NetUNIX, internal.NetUDP, internal.NetUDP4, internal.NetUDP6:
 		return nil, nil
 	}
 
@@ -56,8 +194,8 @@ func (c *Certificate) Config(address, network string) (*tls.Config, error) {
 	if ca != nil {
 		conf.RootCAs = ca
 	}
-	if len(cert.Certificate) >= 0 {
-		conf.Certificates = append(conf.Certificates, cert)
+	if cert != nil {
+		conf.Certificates = append(conf.Certificates, *cert)
 	}
 
 	host, _, err := net.SplitHostPort(address)
@@ -68,6 +206,14 @@ func (c *Certificate) Config(address, network string) (*tls.Config, error) {
 	conf.ServerName = host
 	conf.InsecureSkipVerify = c.InsecureSkipVerify
 
+	p, err := c.pins()
+	if err != nil {
+		return nil, err
+	}
+	if p != nil {
+		p.apply(conf)
+	}
+
 	switch network {
 	case internal.NetQUIC:
 		conf.NextProtos = append(conf.NextProtos, "quic")
@@ -0,0 +1,140 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package client_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.osspkg.com/casecheck"
+
+	"go.osspkg.com/network/client"
+)
+
+func newCA(t *testing.T) (*x509.Certificate, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	casecheck.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	casecheck.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	casecheck.NoError(t, err)
+
+	return cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func writeCA(t *testing.T, filename string) {
+	_, b := newCA(t)
+	casecheck.NoError(t, os.WriteFile(filename, b, 0600))
+}
+
+func TestUnit_CertificateConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeCA(t, filepath.Join(dir, "ca.pem"))
+	casecheck.NoError(t, os.WriteFile(filepath.Join(dir, "bad.crt"), []byte("not a pem"), 0600))
+	casecheck.NoError(t, os.Mkdir(filepath.Join(dir, "certs"), 0700))
+	writeCA(t, filepath.Join(dir, "certs", "a.crt"))
+	writeCA(t, filepath.Join(dir, "certs", "b.pem"))
+
+	conf, err := (&client.Certificate{}).Config("127.0.0.1:443", "tcp")
+	casecheck.NoError(t, err)
+	casecheck.True(t, conf.RootCAs == nil)
+	casecheck.Equal(t, 0, len(conf.Certificates))
+
+	conf, err = (&client.Certificate{
+		CAFile:  filepath.Join(dir, "ca.pem"),
+		CAFiles: []string{filepath.Join(dir, "certs")},
+	}).Config("127.0.0.1:443", "tcp")
+	casecheck.NoError(t, err)
+	casecheck.True(t, conf.RootCAs != nil)
+	casecheck.Equal(t, 0, len(conf.Certificates))
+
+	_, err = (&client.Certificate{CAFile: filepath.Join(dir, "missing.pem")}).Config("127.0.0.1:443", "tcp")
+	casecheck.True(t, errors.Is(err, client.ErrReadCA), err)
+
+	_, err = (&client.Certificate{CAFile: filepath.Join(dir, "bad.crt")}).Config("127.0.0.1:443", "tcp")
+	casecheck.True(t, errors.Is(err, client.ErrInvalidCA), err)
+
+	_, err = (&client.Certificate{CAFiles: []string{dir}}).Config("127.0.0.1:443", "tcp")
+	casecheck.True(t, errors.Is(err, client.ErrInvalidCA), err)
+
+	_, err = (&client.Certificate{CertFile: filepath.Join(dir, "ca.pem")}).Config("127.0.0.1:443", "tcp")
+	casecheck.True(t, errors.Is(err, client.ErrLoadKeyPair), err)
+
+	conf, err = (&client.Certificate{
+		CAFile:   filepath.Join(dir, "ca.pem"),
+		SystemCA: true,
+	}).Config("127.0.0.1:443", "tcp")
+	if errors.Is(err, client.ErrSystemCA) {
+		t.Skip("system ca pool is not available")
+	}
+	casecheck.NoError(t, err)
+	casecheck.True(t, conf.RootCAs != nil)
+}
+
+func TestUnit_CertificatePins(t *testing.T) {
+	cert, _ := newCA(t)
+	other, _ := newCA(t)
+
+	spki := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	leaf := sha256.Sum256(cert.Raw)
+
+	conf, err := (&client.Certificate{
+		PinSHA256: []string{
+			"sha256/" + base64.StdEncoding.EncodeToString(make([]byte, sha256.Size)),
+			"sha256/" + base64.StdEncoding.EncodeToString(spki[:]),
+		},
+	}).Config("127.0.0.1:443", "tcp")
+	casecheck.NoError(t, err)
+	casecheck.True(t, conf.InsecureSkipVerify)
+	casecheck.NoError(t, conf.VerifyConnection(tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}))
+	err = conf.VerifyConnection(tls.ConnectionState{PeerCertificates: []*x509.Certificate{other}})
+	casecheck.True(t, errors.Is(err, client.ErrPinMismatch), err)
+
+	conf, err = (&client.Certificate{
+		CertSHA256:     []string{hex.EncodeToString(leaf[:])},
+		PinVerifyChain: true,
+	}).Config("127.0.0.1:443", "tcp")
+	casecheck.NoError(t, err)
+	casecheck.False(t, conf.InsecureSkipVerify)
+	casecheck.NoError(t, conf.VerifyConnection(tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}))
+	err = conf.VerifyConnection(tls.ConnectionState{})
+	casecheck.True(t, errors.Is(err, client.ErrPinNoPeer), err)
+
+	_, err = (&client.Certificate{PinSHA256: []string{"abc"}}).Config("127.0.0.1:443", "tcp")
+	casecheck.True(t, errors.Is(err, client.ErrInvalidPin), err)
+
+	_, err = (&client.Certificate{
+		PinSHA256:          []string{hex.EncodeToString(spki[:])},
+		PinVerifyChain:     true,
+		InsecureSkipVerify: true,
+	}).Config("127.0.0.1:443", "tcp")
+	casecheck.True(t, errors.Is(err, client.ErrPinConflicts), err)
+}
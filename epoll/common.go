@@ -19,6 +19,13 @@ const (
 	epollEvents = unix.POLLIN | unix.POLLRDHUP | unix.POLLERR | unix.POLLHUP | unix.POLLNVAL
 )
 
+var (
+	ErrDecodeFrame  = errors.New("decode frame")
+	ErrReadBuffer   = errors.New("read buffer limit exceeded")
+	ErrWriteBuffer  = errors.New("write buffer limit exceeded")
+	ErrConnNotFound = errors.New("connection not found")
+)
+
 var (
 	connPool = pool.NewSlicePool[int32](0, 30)
 	buffPool = pool.New[*bytes.Buffer](func() *bytes.Buffer {
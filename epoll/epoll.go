@@ -6,11 +6,16 @@
 package epoll
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"fmt"
+	"io"
 	"net"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"go.osspkg.com/do"
 	"go.osspkg.com/errors"
@@ -23,16 +28,24 @@ import (
 
 type (
 	_epoll struct {
-		fd     int
-		pipe   chan TConnect
-		conn   map[int32]TConnect
+		poll   poller
+		pipe   chan *connect
+		conn   map[int32]*connect
+		ids    map[uint64]*connect
 		events []unix.EpollEvent
 		cfg    Option
 		mux    sync.RWMutex
+		active atomic.Int64
+		// ctx is the Listen context, it is used for connections handled without an event.
+		ctx context.Context
 	}
 	TEpoll interface {
+		Registry
 		Accept(c net.Conn) error
 		Listen(ctx context.Context) (err error)
+		// Backend is the readiness backend in use, BackendEpoll if
+		// BackendIOUringPoll is not available to a loop.
+		Backend() string
 	}
 )
 
@@ -40,116 +53,292 @@ func New(c Option) (TEpoll, error) {
 	if err := c.Validate(); err != nil {
 		return nil, fmt.Errorf("validate config: %w", err)
 	}
-	v, err := unix.EpollCreate1(0)
+	if c.loops() == 1 {
+		return newLoop(c)
+	}
+	return newGroup(c)
+}
+
+func newLoop(c Option) (*_epoll, error) {
+	p, err := newPoller(c)
 	if err != nil {
 		return nil, err
 	}
 	return &_epoll{
-		fd:     v,
+		poll:   p,
 		cfg:    c,
-		pipe:   make(chan TConnect, c.CountEvents),
-		conn:   make(map[int32]TConnect, c.CountEvents),
-		events: make([]unix.EpollEvent, c.WaitIntervalMS),
+		pipe:   make(chan *connect, c.queueSize()),
+		conn:   make(map[int32]*connect, c.CountEvents),
+		ids:    make(map[uint64]*connect, c.CountEvents),
+		events: make([]unix.EpollEvent, c.CountEvents),
+		ctx:    context.Background(),
 	}, nil
 }
 
+func (v *_epoll) Backend() string {
+	return v.poll.backend()
+}
+
+// Accept registers the connection. Wrappers such as *tls.Conn are read and written
+// through the wrapper, only a plain syscall.Conn is accessed by its raw socket.
 func (v *_epoll) Accept(c net.Conn) error {
-	fd64 := netfd.ByConnect(c)
-	fd32 := int32(fd64)
-	err := unix.EpollCtl(v.fd, syscall.EPOLL_CTL_ADD, int(fd64), &unix.EpollEvent{Events: epollEvents, Fd: fd32})
+	var (
+		raw  syscall.RawConn
+		sock *sockConn
+		tc   *tls.Conn
+	)
+	if sc, ok := c.(syscall.Conn); ok {
+		var err error
+		if raw, err = sc.SyscallConn(); err != nil {
+			return errors.Wrap(err, c.Close())
+		}
+	} else if tc, sock, ok = tlsSock(c); ok {
+		raw = sock.raw
+	} else if v.cfg.registered() {
+		return errors.Wrap(fmt.Errorf("epoll %s mode: %T is not syscall.Conn", v.cfg.Mode, c), c.Close())
+	}
+	fd, err := netfd.ByConnect(c)
 	if err != nil {
 		return errors.Wrap(err, c.Close())
 	}
+	fd32 := int32(fd)
+	conn := newConnect(v, c, fd32, raw)
+	conn.tls = tc
+	// the connection is in the map before the first event can come
 	v.mux.Lock()
-	v.conn[fd32] = newConnect(c, fd32)
+	v.conn[fd32] = conn
+	v.ids[conn.id] = conn
 	v.mux.Unlock()
+	if err = v.poll.add(fd32, v.cfg.events()); err != nil {
+		v.mux.Lock()
+		delete(v.conn, fd32)
+		delete(v.ids, conn.id)
+		v.mux.Unlock()
+		return errors.Wrap(err, c.Close())
+	}
+	v.active.Add(1)
+	if sock != nil {
+		sock.conn.Store(conn)
+		// records read together with the handshake are in the TLS buffer, not in the socket
+		v.kick(conn)
+	}
 	return nil
 }
 
+// kick handles the connection once without an event.
+func (v *_epoll) kick(conn *connect) {
+	if !conn.tryAcquire() {
+		return
+	}
+	v.mux.RLock()
+	ctx := v.ctx
+	v.mux.RUnlock()
+
+	do.Async(func() {
+		v.serve(ctx, conn)
+	}, func(e error) {
+		logx.Error("Epoll pipe panic", "err", errors.Unwrap(e), "full", e)
+	})
+}
+
+// Len returns the number of connections served by the loop, including those being handled.
+func (v *_epoll) Len() int {
+	return int(v.active.Load())
+}
+
 func (v *_epoll) removeFD(fd int32) error {
-	return unix.EpollCtl(v.fd, syscall.EPOLL_CTL_DEL, int(fd), nil)
+	return v.poll.del(fd)
 }
 
-func (v *_epoll) getConn(fd int32) (TConnect, bool) {
-	v.mux.Lock()
-	defer v.mux.Unlock()
+func (v *_epoll) lookupConn(fd int32) (*connect, bool) {
+	v.mux.RLock()
+	defer v.mux.RUnlock()
 
 	conn, ok := v.conn[fd]
-	delete(v.conn, fd)
 	return conn, ok
 }
 
-func (v *_epoll) setConn(c TConnect) {
-	v.mux.Lock()
-	defer v.mux.Unlock()
+// takeConn returns the connection for dispatch, the connection stays in the map
+// and its state guards against a second handler. In edge mode an event during
+// handling asks the running handler to drain the socket once more.
+func (v *_epoll) takeConn(fd int32) (*connect, bool) {
+	conn, ok := v.lookupConn(fd)
+	if !ok {
+		return nil, false
+	}
+	if v.cfg.Mode == ModeEdge {
+		return conn, conn.acquire()
+	}
+	return conn, conn.tryAcquire()
+}
+
+// arm updates the epoll interest of the connection by its write queue: EPOLLOUT
+// while data is queued and no EPOLLIN above the high-water mark. Oneshot mode
+// always needs force to rearm. The caller holds the write lock of the connection.
+func (v *_epoll) arm(c *connect, force bool) error {
+	mask := v.cfg.events()
+	if c.wbuf.Len() > 0 {
+		mask |= unix.EPOLLOUT
+	}
+	if c.wbuf.Len() >= v.cfg.writeHighWater() {
+		mask &^= unix.EPOLLIN
+	}
+	if mask == c.mask && !force {
+		return nil
+	}
+	c.mask = mask
+	return v.poll.mod(c.fd, mask)
+}
+
+func (v *_epoll) rearm(c *connect) error {
+	c.wmux.Lock()
+	defer c.wmux.Unlock()
 
-	v.conn[c.FD()] = c
+	if c.closed.Load() {
+		return nil
+	}
+	return v.arm(c, true)
+}
+
+func (v *_epoll) flushConn(fd int32) {
+	conn, ok := v.lookupConn(fd)
+	if !ok {
+		return
+	}
+	err := conn.flush()
+	if err == nil {
+		return
+	}
+	if !isClosedError(err) {
+		logx.Warn("Epoll flush connect", "err", err, "ip", conn.Conn().RemoteAddr())
+	}
+	if err = v.closeConn(fd); err != nil && !isClosedError(err) {
+		logx.Error("Epoll close connect", "err", err)
+	}
 }
 
 func (v *_epoll) closeConn(fd int32) error {
 	v.mux.Lock()
-	defer v.mux.Unlock()
-
 	conn, ok := v.conn[fd]
 	if !ok {
+		v.mux.Unlock()
 		return nil
 	}
 
 	delete(v.conn, fd)
+	delete(v.ids, conn.id)
 
-	return errors.Wrap(
-		v.removeFD(fd),
+	// the fd is removed under the lock, it may be reused by the next accepted connection
+	dropped, err := v.dropConn(conn)
+	v.mux.Unlock()
+	if dropped {
+		v.closed(conn)
+	}
+	return err
+}
+
+// dropConn closes a connection that is already removed from the map,
+// false means it is closed already.
+func (v *_epoll) dropConn(conn *connect) (bool, error) {
+	if !conn.closed.CompareAndSwap(false, true) {
+		return false, nil
+	}
+	v.active.Add(-1)
+	return true, errors.Wrap(
+		v.removeFD(conn.FD()),
 		conn.Conn().Close(),
 	)
 }
 
-func (v *_epoll) closeAll() (err error) {
-	v.mux.Lock()
-	defer v.mux.Unlock()
+// closed reports the dropped connection to Option.OnClose.
+func (v *_epoll) closed(conn *connect) {
+	if v.cfg.OnClose != nil {
+		v.cfg.OnClose(conn)
+	}
+}
 
+func (v *_epoll) closeAll() (err error) {
 	for c := range v.pipe {
-		if err0 := v.closeConn(c.FD()); err0 != nil {
+		dropped, err0 := v.dropConn(c)
+		if err0 != nil && !isClosedError(err0) {
 			err = errors.Wrap(err, err0)
 		}
+		if dropped {
+			v.closed(c)
+		}
 	}
 
-	for fd := range v.conn {
-		if err0 := v.closeConn(fd); err0 != nil {
+	v.mux.Lock()
+	conns := v.conn
+	v.conn = make(map[int32]*connect)
+	v.ids = make(map[uint64]*connect)
+	v.mux.Unlock()
+
+	for _, c := range conns {
+		dropped, err0 := v.dropConn(c)
+		if err0 != nil && !isClosedError(err0) {
 			err = errors.Wrap(err, err0)
 		}
+		if dropped {
+			v.closed(c)
+		}
 	}
-	return
+	return errors.Wrap(err, v.poll.close())
+}
+
+// release closes the poller of a loop which is not listened, e.g. after a failed start.
+func (v *_epoll) release() error {
+	return v.poll.close()
 }
 
 func (v *_epoll) getWaited(list *[]int32) (int, error) {
-	n, err := unix.EpollWait(v.fd, v.events, int(v.cfg.WaitIntervalMS))
-	if err != nil && !errors.Is(err, unix.EINTR) {
+	n, err := v.poll.wait(v.events, int(v.cfg.WaitIntervalMS))
+	if err != nil {
 		return 0, err
 	}
 	if n <= 0 {
 		return 0, nil
 	}
 	for i := 0; i < n; i++ {
-		switch v.events[i].Events {
-		case unix.POLLIN:
-			*list = append(*list, v.events[i].Fd)
-		default:
-			if err = v.closeConn(v.events[i].Fd); err != nil {
-				logx.Error("Epoll close connect", "err", err)
+		ev, fd := v.events[i].Events, v.events[i].Fd
+		if ev&(unix.POLLERR|unix.POLLHUP|unix.POLLNVAL) == 0 {
+			if ev&unix.POLLOUT != 0 {
+				v.flushConn(fd)
+			}
+			// data before a half close is read first, the next read gets io.EOF
+			if ev&unix.POLLIN != 0 {
+				*list = append(*list, fd)
+				continue
+			}
+			if ev&unix.POLLRDHUP == 0 {
+				continue
 			}
 		}
+		if err = v.closeConn(fd); err != nil && !isClosedError(err) {
+			logx.Error("Epoll close connect", "err", err)
+		}
 	}
 
 	return len(*list), nil
 }
 
 func (v *_epoll) Listen(ctx context.Context) (err error) {
+	v.mux.Lock()
+	v.ctx = ctx
+	v.mux.Unlock()
+
 	defer func() {
 		close(v.pipe)
 		err = errors.Wrap(err, v.closeAll())
 	}()
 
-	go v.piping(ctx)
+	if v.cfg.Workers > 0 {
+		for i := uint(0); i < v.cfg.Workers; i++ {
+			go v.worker(ctx)
+		}
+	} else {
+		go v.piping(ctx)
+	}
 
 	for {
 		select {
@@ -169,11 +358,11 @@ func (v *_epoll) Listen(ctx context.Context) (err error) {
 		}
 
 		for _, fd := range list.B {
-			conn, ok := v.getConn(fd)
+			conn, ok := v.takeConn(fd)
 			if !ok {
 				continue
 			}
-			v.pipe <- conn
+			v.enqueue(ctx, conn)
 		}
 
 		connPool.Put(list)
@@ -187,25 +376,12 @@ func (v *_epoll) piping(ctx context.Context) {
 		case <-ctx.Done():
 			return
 
-		case conn := <-v.pipe:
+		case conn, ok := <-v.pipe:
+			if !ok {
+				return
+			}
 			do.Async(func() {
-				defer func() {
-					v.setConn(conn)
-				}()
-
-				e := v.handlingConnect(ctx, conn)
-				if e == nil {
-					return
-				}
-				if !isClosedError(e) {
-					logx.Warn("Epoll handling connect", "err", e, "ip", conn.Conn().RemoteAddr())
-					return
-				}
-				e = v.closeConn(conn.FD())
-				if e == nil || isClosedError(e) {
-					return
-				}
-				logx.Error("Epoll close connect", "err", e)
+				v.serve(ctx, conn)
 			}, func(e error) {
 				logx.Error("Epoll pipe panic", "err", errors.Unwrap(e), "full", e)
 			})
@@ -213,17 +389,187 @@ func (v *_epoll) piping(ctx context.Context) {
 	}
 }
 
-func (v *_epoll) handlingConnect(ctx context.Context, conn TConnect) error {
+// enqueue passes the connection to the handlers, a full queue is handled by Option.QueuePolicy.
+func (v *_epoll) enqueue(ctx context.Context, conn *connect) {
+	if v.cfg.Metrics != nil {
+		conn.ready = time.Now()
+	}
+	if v.cfg.QueuePolicy == QueueDrop || v.cfg.QueuePolicy == QueueClose {
+		select {
+		case v.pipe <- conn:
+			v.reportQueue()
+		default:
+			v.reject(conn)
+		}
+		return
+	}
+	select {
+	case v.pipe <- conn:
+		v.reportQueue()
+	case <-ctx.Done():
+		conn.idle()
+	}
+}
+
+func (v *_epoll) reject(conn *connect) {
+	if v.cfg.Metrics != nil {
+		v.cfg.Metrics.Rejected(v.cfg.QueuePolicy)
+	}
+	if v.cfg.QueuePolicy == QueueClose {
+		if err := v.closeConn(conn.FD()); err != nil && !isClosedError(err) {
+			logx.Error("Epoll close connect", "err", err)
+		}
+		return
+	}
+	// the data stays in the socket: level mode gets the next event at once,
+	// oneshot mode is rearmed and edge mode waits for new data
+	conn.idle()
+	if v.cfg.Mode != ModeOneShot {
+		return
+	}
+	if err := v.rearm(conn); err != nil {
+		if err = v.closeConn(conn.FD()); err != nil && !isClosedError(err) {
+			logx.Error("Epoll close connect", "err", err)
+		}
+	}
+}
+
+func (v *_epoll) reportQueue() {
+	if v.cfg.Metrics != nil {
+		v.cfg.Metrics.QueueDepth(len(v.pipe))
+	}
+}
+
+// worker is one of Option.Workers handling queued connections in order.
+func (v *_epoll) worker(ctx context.Context) {
+	for conn := range v.pipe {
+		v.reportQueue()
+		v.serveSafe(ctx, conn)
+	}
+}
+
+func (v *_epoll) serveSafe(ctx context.Context, conn *connect) {
+	defer func() {
+		if e := recover(); e != nil {
+			logx.Error("Epoll worker panic", "err", fmt.Errorf("%+v", e))
+			if err := v.closeConn(conn.FD()); err != nil && !isClosedError(err) {
+				logx.Error("Epoll close connect", "err", err)
+			}
+		}
+	}()
+	v.serve(ctx, conn)
+}
+
+func (v *_epoll) serve(ctx context.Context, conn *connect) {
+	if ready := conn.ready; v.cfg.Metrics != nil && !ready.IsZero() {
+		// ready is read before the connection is released to the loop
+		defer func() {
+			v.cfg.Metrics.HandleLatency(time.Since(ready))
+		}()
+	}
+	for {
+		if e := v.handlingConnect(ctx, conn); e != nil {
+			if !isClosedError(e) {
+				logx.Warn("Epoll handling connect", "err", e, "ip", conn.Conn().RemoteAddr())
+			}
+			if isClosedError(e) || errors.Is(e, ErrDecodeFrame) ||
+				errors.Is(e, ErrReadBuffer) || errors.Is(e, ErrWriteBuffer) {
+				if e = v.closeConn(conn.FD()); e != nil && !isClosedError(e) {
+					logx.Error("Epoll close connect", "err", e)
+				}
+				return
+			}
+		}
+
+		switch v.cfg.Mode {
+		case ModeEdge:
+			if conn.release() {
+				return
+			}
+		case ModeOneShot:
+			// no event comes until rearm, the state only orders the handlers
+			conn.idle()
+			if e := v.rearm(conn); e != nil {
+				if e = v.closeConn(conn.FD()); e != nil && !isClosedError(e) {
+					logx.Error("Epoll close connect", "err", e)
+				}
+			}
+			return
+		default:
+			conn.idle()
+			return
+		}
+	}
+}
+
+// readConn reads the available data, until EAGAIN in edge and oneshot modes.
+func (v *_epoll) readConn(w *bytes.Buffer, conn *connect) (int, error) {
+	if conn.tls != nil {
+		return conn.drainTLS(w)
+	}
+	if v.cfg.registered() {
+		return conn.drain(w)
+	}
+	n, err := ioutils.Copy(w, conn.Conn())
+	if n == 0 && err == nil {
+		// io.EOF is not returned by the copy, a ready socket without data is closed by the peer
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (v *_epoll) handlingConnect(ctx context.Context, conn *connect) error {
+	if v.cfg.Decoder != nil {
+		return v.handlingFrames(ctx, conn)
+	}
+
 	buff := buffPool.Get()
 	defer func() {
+		// the handler may leave unread data
+		buff.Reset()
 		buffPool.Put(buff)
 	}()
-	n, err := ioutils.Copy(buff, conn.Conn())
-	if err != nil {
+	n, err := v.readConn(buff, conn)
+	if n == 0 {
 		return err
 	}
+	if e := v.cfg.Handler(context.WithoutCancel(ctx), conn, buff); e != nil {
+		return e
+	}
+	return err
+}
+
+func (v *_epoll) handlingFrames(ctx context.Context, conn *connect) error {
+	buff := conn.readBuffer()
+	defer conn.releaseBuffer()
+
+	n, err := v.readConn(buff, conn)
 	if n == 0 {
-		return nil
+		return err
+	}
+
+	data := buff.Bytes()
+	for len(data) > 0 {
+		advance, frame, e := v.cfg.Decoder.Decode(data, false)
+		if e != nil {
+			return fmt.Errorf("%w: %w", ErrDecodeFrame, e)
+		}
+		if advance <= 0 {
+			break
+		}
+		data = data[advance:]
+		if frame == nil {
+			continue
+		}
+		if e = v.cfg.Handler(context.WithoutCancel(ctx), conn, bytes.NewReader(frame)); e != nil {
+			buff.Next(buff.Len() - len(data))
+			return e
+		}
+	}
+	buff.Next(buff.Len() - len(data))
+
+	if buff.Len() > v.cfg.maxReadBuffer() {
+		return fmt.Errorf("%w: %d bytes", ErrReadBuffer, buff.Len())
 	}
-	return v.cfg.Handler(context.WithoutCancel(ctx), conn.Conn(), buff)
+	return err
 }
@@ -6,25 +6,84 @@
 package epoll
 
 import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
 	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"go.osspkg.com/errors"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	stateIdle int32 = iota
+	stateBusy
+	statePending
 )
 
 type (
 	connect struct {
 		conn net.Conn
 		fd   int32
+		id   uint64
+		// buff keeps an incomplete frame between events, it is used only
+		// by the goroutine holding the busy state.
+		buff *bytes.Buffer
+		// raw reads the socket directly in edge and oneshot modes and writes it without blocking.
+		raw syscall.RawConn
+		// tls is set for a connection accepted by ServerTCP with SSL, its records
+		// are read from the socket without blocking.
+		tls    *tls.Conn
+		state  atomic.Int32
+		closed atomic.Bool
+		// ready is the time of the dispatched event, it is set only with Option.Metrics.
+		ready time.Time
+
+		loop *_epoll
+		// wmux guards the write queue and the epoll interest mask.
+		wmux sync.Mutex
+		wbuf bytes.Buffer
+		mask uint32
+
+		meta   sync.RWMutex
+		tags   map[string]struct{}
+		values map[string]any
 	}
 
+	// TConnect is a served connection, it is passed to Option.Handler as the writer.
 	TConnect interface {
+		// ID is unique for the process lifetime, unlike FD which is reused by the kernel.
+		ID() uint64
 		FD() int32
 		Conn() net.Conn
+		// Write queues data for the connection, it does not block on a slow reader.
+		Write(p []byte) (int, error)
+		// Close removes the connection from the epoll and closes it.
+		Close() error
+
+		Tag(tags ...string)
+		Untag(tags ...string)
+		// HasTag reports whether the connection has any of the tags.
+		HasTag(tags ...string) bool
+		Tags() []string
+		SetValue(key string, val any)
+		Value(key string) (any, bool)
 	}
 )
 
-func newConnect(c net.Conn, fd int32) TConnect {
+func newConnect(loop *_epoll, c net.Conn, fd int32, raw syscall.RawConn) *connect {
 	return &connect{
 		conn: c,
 		fd:   fd,
+		id:   nextConnID(),
+		raw:  raw,
+		loop: loop,
+		mask: loop.cfg.events(),
 	}
 }
 
@@ -35,3 +94,257 @@ func (v *connect) Conn() net.Conn {
 func (v *connect) FD() int32 {
 	return v.fd
 }
+
+func (v *connect) ID() uint64 {
+	return v.id
+}
+
+func (v *connect) Close() error {
+	return v.loop.closeConn(v.fd)
+}
+
+func (v *connect) Tag(tags ...string) {
+	v.meta.Lock()
+	defer v.meta.Unlock()
+
+	if v.tags == nil {
+		v.tags = make(map[string]struct{}, len(tags))
+	}
+	for _, t := range tags {
+		v.tags[t] = struct{}{}
+	}
+}
+
+func (v *connect) Untag(tags ...string) {
+	v.meta.Lock()
+	defer v.meta.Unlock()
+
+	for _, t := range tags {
+		delete(v.tags, t)
+	}
+}
+
+func (v *connect) HasTag(tags ...string) bool {
+	v.meta.RLock()
+	defer v.meta.RUnlock()
+
+	for _, t := range tags {
+		if _, ok := v.tags[t]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *connect) Tags() []string {
+	v.meta.RLock()
+	defer v.meta.RUnlock()
+
+	list := make([]string, 0, len(v.tags))
+	for t := range v.tags {
+		list = append(list, t)
+	}
+	return list
+}
+
+func (v *connect) SetValue(key string, val any) {
+	v.meta.Lock()
+	defer v.meta.Unlock()
+
+	if v.values == nil {
+		v.values = make(map[string]any)
+	}
+	v.values[key] = val
+}
+
+func (v *connect) Value(key string) (any, bool) {
+	v.meta.RLock()
+	defer v.meta.RUnlock()
+
+	val, ok := v.values[key]
+	return val, ok
+}
+
+func (v *connect) readBuffer() *bytes.Buffer {
+	if v.buff == nil {
+		v.buff = bytes.NewBuffer(make([]byte, 0, 1024))
+	}
+	return v.buff
+}
+
+func (v *connect) releaseBuffer() {
+	if v.buff != nil && v.buff.Len() == 0 && v.buff.Cap() > 64<<10 {
+		v.buff = nil
+	}
+}
+
+// acquire marks the connection busy, false means it is already handled
+// and the handler is asked to drain the socket once more.
+func (v *connect) acquire() bool {
+	for {
+		switch v.state.Load() {
+		case stateIdle:
+			if v.state.CompareAndSwap(stateIdle, stateBusy) {
+				return true
+			}
+		case stateBusy:
+			if v.state.CompareAndSwap(stateBusy, statePending) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+}
+
+// tryAcquire marks the connection busy if it is not handled yet.
+func (v *connect) tryAcquire() bool {
+	return v.state.CompareAndSwap(stateIdle, stateBusy)
+}
+
+func (v *connect) idle() {
+	v.state.Store(stateIdle)
+}
+
+// release returns false if an event came during handling and the socket must be drained again.
+func (v *connect) release() bool {
+	if v.state.CompareAndSwap(stateBusy, stateIdle) {
+		return true
+	}
+	v.state.Store(stateBusy)
+	return false
+}
+
+// drain reads the socket until EAGAIN, io.EOF is returned with the data read before it.
+func (v *connect) drain(w *bytes.Buffer) (n int, err error) {
+	e := v.raw.Read(func(fd uintptr) bool {
+		for {
+			b := w.AvailableBuffer()
+			if cap(b) < 1024 {
+				w.Grow(4096)
+				b = w.AvailableBuffer()
+			}
+			b = b[:cap(b)]
+			m, e := unix.Read(int(fd), b)
+			switch {
+			case m > 0:
+				w.Write(b[:m]) // nolint: errcheck
+				n += m
+			case m == 0 && e == nil:
+				err = io.EOF
+				return true
+			case e == unix.EINTR:
+			case e == unix.EAGAIN:
+				return true
+			default:
+				err = e
+				return true
+			}
+		}
+	})
+	if err == nil {
+		err = e
+	}
+	return
+}
+
+// Write sends as much of p as the socket accepts and queues the rest,
+// the loop flushes the queue on EPOLLOUT. The connection is closed when
+// the queue exceeds Option.MaxWriteBuffer.
+func (v *connect) Write(p []byte) (int, error) {
+	if v.tls != nil {
+		return v.tls.Write(p)
+	}
+	return v.queue(p)
+}
+
+// queue writes p to the socket, the rest is queued until EPOLLOUT.
+func (v *connect) queue(p []byte) (int, error) {
+	n, err := v.enqueue(p)
+	if errors.Is(err, ErrWriteBuffer) {
+		// out of the write lock, closing a TLS connection writes an alert
+		err = errors.Wrap(err, v.loop.closeConn(v.fd))
+	}
+	return n, err
+}
+
+func (v *connect) enqueue(p []byte) (int, error) {
+	v.wmux.Lock()
+	defer v.wmux.Unlock()
+
+	if v.closed.Load() {
+		return 0, net.ErrClosed
+	}
+	if v.raw == nil {
+		return v.conn.Write(p)
+	}
+
+	size := len(p)
+	if v.wbuf.Len() == 0 {
+		n, err := v.writeRaw(p)
+		if err != nil {
+			return n, err
+		}
+		if p = p[n:]; len(p) == 0 {
+			return size, nil
+		}
+	}
+	if limit := v.loop.cfg.maxWriteBuffer(); v.wbuf.Len()+len(p) > limit {
+		return size - len(p), fmt.Errorf("%w: %d bytes", ErrWriteBuffer, v.wbuf.Len()+len(p))
+	}
+	v.wbuf.Write(p) // nolint: errcheck
+	return size, v.loop.arm(v, false)
+}
+
+// Buffered returns the size of the queued data.
+func (v *connect) Buffered() int {
+	v.wmux.Lock()
+	defer v.wmux.Unlock()
+
+	return v.wbuf.Len()
+}
+
+func (v *connect) flush() error {
+	v.wmux.Lock()
+	defer v.wmux.Unlock()
+
+	if v.closed.Load() {
+		return nil
+	}
+	if v.wbuf.Len() > 0 {
+		n, err := v.writeRaw(v.wbuf.Bytes())
+		v.wbuf.Next(n)
+		if err != nil {
+			return err
+		}
+	}
+	return v.loop.arm(v, v.loop.cfg.Mode == ModeOneShot)
+}
+
+// writeRaw writes until EAGAIN without waiting for the socket.
+func (v *connect) writeRaw(p []byte) (n int, err error) {
+	e := v.raw.Write(func(fd uintptr) bool {
+		for n < len(p) {
+			m, e := unix.Write(int(fd), p[n:])
+			switch {
+			case m > 0:
+				n += m
+			case e == unix.EINTR:
+			case e == unix.EAGAIN:
+				return true
+			case m == 0 && e == nil:
+				// nothing is written without an error, the loop would spin
+				err = io.ErrShortWrite
+				return true
+			default:
+				err = e
+				return true
+			}
+		}
+		return true
+	})
+	if err == nil {
+		err = e
+	}
+	return
+}
@@ -0,0 +1,593 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package epoll_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unsafe"
+
+	"go.osspkg.com/casecheck"
+	"golang.org/x/sys/unix"
+
+	"go.osspkg.com/network/epoll"
+	"go.osspkg.com/network/framing"
+)
+
+// wantBackend is the backend which the loop must use, io_uring poll needs
+// the multishot poll of Linux 5.13 (IORING_FEAT_RSRC_TAGS is of the same release).
+func wantBackend(backend string) string {
+	if backend != epoll.BackendIOUringPoll {
+		return backend
+	}
+	var params [30]uint32 // struct io_uring_params
+	fd, _, errno := unix.Syscall(unix.SYS_IO_URING_SETUP, 4, uintptr(unsafe.Pointer(&params[0])), 0)
+	if errno != 0 {
+		return epoll.BackendEpoll
+	}
+	unix.Close(int(fd)) // nolint: errcheck
+	if params[5]&(1<<10) == 0 {
+		return epoll.BackendEpoll
+	}
+	return backend
+}
+
+func TestUnit_EpollDecoder(t *testing.T) {
+	for _, backend := range []string{epoll.BackendEpoll, epoll.BackendIOUringPoll} {
+		for _, mode := range []string{epoll.ModeLevel, epoll.ModeEdge, epoll.ModeOneShot} {
+			t.Run(backend+"/"+mode, func(t *testing.T) {
+				testEpollDecoder(t, backend, mode)
+			})
+		}
+	}
+}
+
+func testEpollDecoder(t *testing.T, backend, mode string) {
+	codec := framing.NewDelimiter([]byte("\n"), 64)
+
+	ep, err := epoll.New(epoll.Option{
+		Handler: framing.FrameHandler(codec, func(_ context.Context, msg []byte) ([]byte, error) {
+			return append([]byte(">> "), msg...), nil
+		}),
+		Decoder:        codec,
+		Mode:           mode,
+		Backend:        backend,
+		CountEvents:    10,
+		WaitIntervalMS: 10,
+	})
+	casecheck.NoError(t, err)
+	casecheck.Equal(t, wantBackend(backend), ep.Backend())
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	casecheck.NoError(t, err)
+	defer l.Close() // nolint: errcheck
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	go ep.Listen(ctx) // nolint: errcheck
+	go func() {
+		for {
+			conn, e := l.Accept()
+			if e != nil {
+				return
+			}
+			casecheck.NoError(t, ep.Accept(conn))
+		}
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	casecheck.NoError(t, err)
+	defer conn.Close() // nolint: errcheck
+
+	for _, chunk := range []string{"he", "llo\nwor", "ld\nfoo\nbar\n"} {
+		_, err = conn.Write([]byte(chunk))
+		casecheck.NoError(t, err)
+		time.Sleep(30 * time.Millisecond)
+	}
+
+	casecheck.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	r := bufio.NewReader(conn)
+	for _, want := range []string{">> hello\n", ">> world\n", ">> foo\n", ">> bar\n"} {
+		got, err := r.ReadString('\n')
+		casecheck.NoError(t, err)
+		casecheck.Equal(t, want, got)
+	}
+
+	_, err = conn.Write(make([]byte, 100))
+	casecheck.NoError(t, err)
+	_, err = r.ReadByte()
+	casecheck.Error(t, err)
+	// the connection is closed by the server, not by the deadline
+	var ne net.Error
+	casecheck.False(t, errors.As(err, &ne) && ne.Timeout(), err)
+}
+
+func TestUnit_EpollPeerClose(t *testing.T) {
+	for _, mode := range []string{epoll.ModeLevel, epoll.ModeEdge, epoll.ModeOneShot} {
+		t.Run(mode, func(t *testing.T) {
+			closed := make(chan uint64, 2)
+			ep, err := epoll.New(epoll.Option{
+				Handler: func(_ context.Context, w io.Writer, r io.Reader) error {
+					_, e := io.Copy(w, r)
+					return e
+				},
+				OnClose:        func(c epoll.TConnect) { closed <- c.ID() },
+				Mode:           mode,
+				CountEvents:    10,
+				WaitIntervalMS: 10,
+			})
+			casecheck.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.TODO())
+			done := make(chan error)
+			go func() { done <- ep.Listen(ctx) }()
+
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			casecheck.NoError(t, err)
+			cli, err := net.Dial("tcp", l.Addr().String())
+			casecheck.NoError(t, err)
+			srv, err := l.Accept()
+			casecheck.NoError(t, err)
+			casecheck.NoError(t, l.Close())
+			casecheck.NoError(t, ep.Accept(srv))
+
+			_, err = cli.Write([]byte("bye"))
+			casecheck.NoError(t, err)
+			got := make([]byte, 3)
+			_, err = io.ReadFull(cli, got)
+			casecheck.NoError(t, err)
+			casecheck.NoError(t, cli.Close())
+
+			// the connection closed by the client is removed from the loop before OnClose
+			var id uint64
+			select {
+			case id = <-closed:
+			case <-time.After(5 * time.Second):
+				t.Fatal("OnClose is not called")
+			}
+			_, ok := ep.Lookup(id)
+			casecheck.False(t, ok)
+			count := 0
+			ep.Range(func(epoll.TConnect) bool {
+				count++
+				return true
+			})
+			casecheck.Equal(t, 0, count)
+
+			cancel()
+			casecheck.NoError(t, <-done)
+			// the connection is reported once
+			casecheck.Equal(t, 0, len(closed))
+		})
+	}
+}
+
+func TestUnit_EpollLoops(t *testing.T) {
+	for _, balance := range []string{epoll.BalanceRoundRobin, epoll.BalanceLeastConns} {
+		t.Run(balance, func(t *testing.T) {
+			ep, err := epoll.New(epoll.Option{
+				Handler: func(_ context.Context, w io.Writer, r io.Reader) error {
+					_, e := io.Copy(w, r)
+					return e
+				},
+				CountEvents:    10,
+				WaitIntervalMS: 10,
+				Loops:          4,
+				Balance:        balance,
+			})
+			casecheck.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.TODO())
+			done := make(chan error)
+			go func() { done <- ep.Listen(ctx) }()
+
+			conns := make([]net.Conn, 0, 10)
+			for i := 0; i < 10; i++ {
+				l, err := net.Listen("tcp", "127.0.0.1:0")
+				casecheck.NoError(t, err)
+				cli, err := net.Dial("tcp", l.Addr().String())
+				casecheck.NoError(t, err)
+				srv, err := l.Accept()
+				casecheck.NoError(t, err)
+				casecheck.NoError(t, l.Close())
+				casecheck.NoError(t, ep.Accept(srv))
+				conns = append(conns, cli)
+			}
+
+			for i, conn := range conns {
+				msg := []byte{'a' + byte(i)}
+				_, err = conn.Write(msg)
+				casecheck.NoError(t, err)
+				casecheck.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+				got := make([]byte, 1)
+				_, err = io.ReadFull(conn, got)
+				casecheck.NoError(t, err)
+				casecheck.Equal(t, msg, got)
+			}
+
+			cancel()
+			casecheck.NoError(t, <-done)
+			for _, conn := range conns {
+				_ = conn.Close()
+			}
+		})
+	}
+}
+
+// BenchmarkEpollModes compares the backends, io_uring_poll only waits for readiness
+// and is not expected to beat epoll.
+func BenchmarkEpollModes(b *testing.B) {
+	for _, backend := range []string{epoll.BackendEpoll, epoll.BackendIOUringPoll} {
+		for _, mode := range []string{epoll.ModeLevel, epoll.ModeEdge, epoll.ModeOneShot} {
+			b.Run(backend+"/"+mode, func(b *testing.B) {
+				benchmarkEpollMode(b, backend, mode)
+			})
+		}
+	}
+}
+
+func benchmarkEpollMode(b *testing.B, backend, mode string) {
+	ep, err := epoll.New(epoll.Option{
+		Handler: func(_ context.Context, w io.Writer, r io.Reader) error {
+			_, e := io.Copy(w, r)
+			return e
+		},
+		CountEvents:    100,
+		WaitIntervalMS: 10,
+		Loops:          1,
+		Mode:           mode,
+		Backend:        backend,
+	})
+	casecheck.NoError(b, err)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	casecheck.NoError(b, err)
+	defer l.Close() // nolint: errcheck
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go ep.Listen(ctx) // nolint: errcheck
+	go func() {
+		for {
+			conn, e := l.Accept()
+			if e != nil {
+				return
+			}
+			casecheck.NoError(b, ep.Accept(conn))
+		}
+	}()
+
+	msg := make([]byte, 64)
+	b.SetBytes(int64(len(msg)))
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		casecheck.NoError(b, err)
+		defer conn.Close() // nolint: errcheck
+
+		buf := make([]byte, len(msg))
+		for pb.Next() {
+			if _, err = conn.Write(msg); err != nil {
+				b.Error(err)
+				return
+			}
+			if _, err = io.ReadFull(conn, buf); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func TestUnit_EpollWriteQueue(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789abcdef"), 16<<10)
+
+	for _, backend := range []string{epoll.BackendEpoll, epoll.BackendIOUringPoll} {
+		for _, mode := range []string{epoll.ModeLevel, epoll.ModeEdge, epoll.ModeOneShot} {
+			t.Run(backend+"/"+mode, func(t *testing.T) {
+				testEpollWriteQueue(t, backend, mode, payload)
+			})
+		}
+	}
+}
+
+func testEpollWriteQueue(t *testing.T, backend, mode string, payload []byte) {
+	ep, err := epoll.New(epoll.Option{
+		Handler: func(_ context.Context, w io.Writer, r io.Reader) error {
+			if _, e := io.Copy(io.Discard, r); e != nil {
+				return e
+			}
+			n, e := w.Write(payload)
+			if e == nil && n != len(payload) {
+				e = io.ErrShortWrite
+			}
+			return e
+		},
+		CountEvents:    10,
+		WaitIntervalMS: 10,
+		Loops:          1,
+		Mode:           mode,
+		Backend:        backend,
+		WriteHighWater: 4 << 10,
+		MaxWriteBuffer: 1 << 20,
+	})
+	casecheck.NoError(t, err)
+	casecheck.Equal(t, wantBackend(backend), ep.Backend())
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go ep.Listen(ctx) // nolint: errcheck
+
+	conn := dialEpoll(t, ep)
+	defer conn.Close() // nolint: errcheck
+
+	_, err = conn.Write([]byte("get"))
+	casecheck.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+
+	casecheck.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	got := make([]byte, len(payload))
+	_, err = io.ReadFull(conn, got)
+	casecheck.NoError(t, err)
+	casecheck.True(t, bytes.Equal(payload, got))
+
+	_, err = conn.Write([]byte("get"))
+	casecheck.NoError(t, err)
+	_, err = io.ReadFull(conn, got)
+	casecheck.NoError(t, err)
+	casecheck.True(t, bytes.Equal(payload, got))
+}
+
+func TestUnit_EpollWriteLimit(t *testing.T) {
+	werr := make(chan error, 1)
+	ep, err := epoll.New(epoll.Option{
+		Handler: func(_ context.Context, w io.Writer, r io.Reader) error {
+			_, e := w.Write(make([]byte, 1<<20))
+			werr <- e
+			return e
+		},
+		CountEvents:    10,
+		WaitIntervalMS: 10,
+		Loops:          1,
+		WriteHighWater: 16 << 10,
+		MaxWriteBuffer: 64 << 10,
+	})
+	casecheck.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go ep.Listen(ctx) // nolint: errcheck
+
+	conn := dialEpoll(t, ep)
+	defer conn.Close() // nolint: errcheck
+
+	_, err = conn.Write([]byte("get"))
+	casecheck.NoError(t, err)
+	casecheck.True(t, errors.Is(<-werr, epoll.ErrWriteBuffer))
+
+	casecheck.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, err := io.Copy(io.Discard, conn)
+	casecheck.True(t, n < 1<<20, n, err)
+}
+
+// dialEpoll returns the client side of a connection served by ep,
+// the server side has a small send buffer to make the writes queue.
+func dialEpoll(t testing.TB, ep epoll.TEpoll) net.Conn {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	casecheck.NoError(t, err)
+	defer l.Close() // nolint: errcheck
+
+	cli, err := net.Dial("tcp", l.Addr().String())
+	casecheck.NoError(t, err)
+	srv, err := l.Accept()
+	casecheck.NoError(t, err)
+	casecheck.NoError(t, srv.(*net.TCPConn).SetWriteBuffer(4096))
+	casecheck.NoError(t, ep.Accept(srv))
+	return cli
+}
+
+func TestUnit_EpollRegistry(t *testing.T) {
+	joined := make(chan uint64, 3)
+	ep, err := epoll.New(epoll.Option{
+		Handler: func(_ context.Context, w io.Writer, r io.Reader) error {
+			b, e := io.ReadAll(r)
+			if e != nil {
+				return e
+			}
+			c := w.(epoll.TConnect)
+			c.Tag(string(b))
+			c.SetValue("room", string(b))
+			joined <- c.ID()
+			return nil
+		},
+		CountEvents:    10,
+		WaitIntervalMS: 10,
+		Loops:          2,
+	})
+	casecheck.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go ep.Listen(ctx) // nolint: errcheck
+
+	conns := make(map[uint64]net.Conn)
+	for _, room := range []string{"a", "b", "a"} {
+		conn := dialEpoll(t, ep)
+		defer conn.Close() // nolint: errcheck
+		_, err = conn.Write([]byte(room))
+		casecheck.NoError(t, err)
+		conns[<-joined] = conn
+	}
+
+	count := 0
+	ep.Range(func(c epoll.TConnect) bool {
+		count++
+		return true
+	})
+	casecheck.Equal(t, 3, count)
+	casecheck.Equal(t, 2, ep.Broadcast([]byte("to a\n"), "a"))
+	casecheck.Equal(t, 3, ep.Broadcast([]byte("to all\n")))
+
+	for id, conn := range conns {
+		c, ok := ep.Lookup(id)
+		casecheck.True(t, ok)
+		room, _ := c.Value("room")
+		casecheck.NoError(t, ep.Send(id, []byte("to "+room.(string)+"\n")))
+
+		want := []string{"to all\n", "to " + room.(string) + "\n"}
+		if c.HasTag("a") {
+			want = append([]string{"to a\n"}, want...)
+		}
+		casecheck.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		r := bufio.NewReader(conn)
+		for _, w := range want {
+			got, err := r.ReadString('\n')
+			casecheck.NoError(t, err)
+			casecheck.Equal(t, w, got)
+		}
+		casecheck.NoError(t, c.Close())
+		_, ok = ep.Lookup(id)
+		casecheck.False(t, ok)
+	}
+	casecheck.True(t, errors.Is(ep.Send(0, nil), epoll.ErrConnNotFound))
+}
+
+type testMetrics struct {
+	depth    chan int
+	rejected chan string
+	latency  atomic.Int64
+}
+
+func (v *testMetrics) QueueDepth(depth int) {
+	select {
+	case v.depth <- depth:
+	default:
+	}
+}
+func (v *testMetrics) HandleLatency(time.Duration) { v.latency.Add(1) }
+func (v *testMetrics) Rejected(policy string)      { v.rejected <- policy }
+
+func TestUnit_EpollWorkers(t *testing.T) {
+	started, unblock := make(chan struct{}, 4), make(chan struct{})
+	metrics := &testMetrics{depth: make(chan int, 100), rejected: make(chan string, 4)}
+	ep, err := epoll.New(epoll.Option{
+		Handler: func(_ context.Context, w io.Writer, r io.Reader) error {
+			started <- struct{}{}
+			<-unblock
+			_, e := io.Copy(w, r)
+			return e
+		},
+		CountEvents:    10,
+		WaitIntervalMS: 10,
+		Loops:          1,
+		Workers:        2,
+		QueueSize:      1,
+		QueuePolicy:    epoll.QueueClose,
+		Metrics:        metrics,
+	})
+	casecheck.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go ep.Listen(ctx) // nolint: errcheck
+
+	conns := make([]net.Conn, 0, 4)
+	for i := 0; i < 4; i++ {
+		conn := dialEpoll(t, ep)
+		defer conn.Close() // nolint: errcheck
+		conns = append(conns, conn)
+	}
+	for i, conn := range conns {
+		_, err = conn.Write([]byte{'a' + byte(i)})
+		casecheck.NoError(t, err)
+		switch i {
+		case 0, 1:
+			<-started
+		case 2:
+			// the third one waits in the queue
+			for d := range metrics.depth {
+				if d == 1 {
+					break
+				}
+			}
+		}
+	}
+	casecheck.Equal(t, epoll.QueueClose, <-metrics.rejected)
+	close(unblock)
+
+	for i, conn := range conns {
+		casecheck.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		got := make([]byte, 1)
+		_, err = io.ReadFull(conn, got)
+		if i == 3 {
+			// closed with unread data, it may be EOF or a reset
+			casecheck.Error(t, err)
+			continue
+		}
+		casecheck.NoError(t, err)
+		casecheck.Equal(t, 'a'+byte(i), got[0])
+	}
+	// the latency is reported after the answer is written
+	for i := 0; i < 100 && metrics.latency.Load() < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	casecheck.Equal(t, int64(3), metrics.latency.Load())
+}
+
+func TestUnit_EpollLoopsFailure(t *testing.T) {
+	var limit unix.Rlimit
+	casecheck.NoError(t, unix.Getrlimit(unix.RLIMIT_NOFILE, &limit))
+	fd, err := unix.Open("/dev/null", unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	casecheck.NoError(t, err)
+	casecheck.NoError(t, unix.Close(fd))
+
+	// fd is the lowest free descriptor, only the first loop gets one
+	low := limit
+	low.Cur = uint64(fd + 1)
+	casecheck.NoError(t, unix.Setrlimit(unix.RLIMIT_NOFILE, &low))
+	t.Cleanup(func() {
+		casecheck.NoError(t, unix.Setrlimit(unix.RLIMIT_NOFILE, &limit))
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		_, e := epoll.New(epoll.Option{
+			Handler:        func(context.Context, io.Writer, io.Reader) error { return nil },
+			CountEvents:    10,
+			WaitIntervalMS: 10,
+			Loops:          4,
+		})
+		done <- e
+	}()
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("New hangs after a failed loop")
+	}
+	casecheck.True(t, errors.Is(err, unix.EMFILE), err)
+}
+
+func TestUnit_EpollOptionWriteBuffer(t *testing.T) {
+	opt := epoll.Option{
+		Handler:        func(context.Context, io.Writer, io.Reader) error { return nil },
+		CountEvents:    10,
+		WaitIntervalMS: 10,
+		MaxWriteBuffer: 64 << 10,
+	}
+	// the default high water is 1 MiB
+	casecheck.Error(t, opt.Validate())
+
+	opt.WriteHighWater = 16 << 10
+	casecheck.NoError(t, opt.Validate())
+}
@@ -0,0 +1,94 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package epoll
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+
+	"go.osspkg.com/errors"
+)
+
+// _group runs several independent event loops and spreads accepted connections across them.
+type _group struct {
+	registries
+	loops []*_epoll
+	cfg   Option
+	next  atomic.Uint64
+}
+
+func newGroup(c Option) (*_group, error) {
+	v := &_group{
+		cfg:   c,
+		loops: make([]*_epoll, 0, c.loops()),
+	}
+	for i := 0; i < c.loops(); i++ {
+		loop, err := newLoop(c)
+		if err != nil {
+			// the loops have no connections yet and their pipes are not closed,
+			// so only the pollers are closed
+			return nil, errors.Wrap(err, v.release())
+		}
+		v.loops = append(v.loops, loop)
+		v.registries = append(v.registries, loop)
+	}
+	return v, nil
+}
+
+// release closes the pollers of the loops which are not listened.
+func (v *_group) release() (err error) {
+	for _, l := range v.loops {
+		err = errors.Wrap(err, l.release())
+	}
+	return
+}
+
+// Backend is BackendEpoll if a loop falls back to it.
+func (v *_group) Backend() string {
+	for _, l := range v.loops {
+		if l.Backend() == BackendEpoll {
+			return BackendEpoll
+		}
+	}
+	return v.loops[0].Backend()
+}
+
+func (v *_group) Accept(c net.Conn) error {
+	return v.pick().Accept(c)
+}
+
+func (v *_group) pick() *_epoll {
+	if v.cfg.Balance == BalanceLeastConns {
+		best := v.loops[0]
+		for _, l := range v.loops[1:] {
+			if l.Len() < best.Len() {
+				best = l
+			}
+		}
+		return best
+	}
+	return v.loops[(v.next.Add(1)-1)%uint64(len(v.loops))]
+}
+
+func (v *_group) Listen(ctx context.Context) (err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, len(v.loops))
+	for _, loop := range v.loops {
+		loop := loop
+		go func() {
+			e := loop.Listen(ctx)
+			cancel()
+			errs <- e
+		}()
+	}
+	for range v.loops {
+		err = errors.Wrap(err, <-errs)
+	}
+	return
+}
@@ -9,14 +9,106 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"runtime"
+	"time"
+
+	"golang.org/x/sys/unix"
 )
 
 type (
 	Option struct {
+		// Handler gets the connection as w, it can be asserted to TConnect.
 		Handler        func(ctx context.Context, w io.Writer, r io.Reader) error
 		CountEvents    uint
 		WaitIntervalMS uint
+		// Decoder splits the connection data into frames, Handler is called once per frame
+		// and an incomplete frame is kept until the next read. Without it Handler gets raw reads.
+		Decoder Decoder
+		// MaxReadBuffer limits buffered data of an incomplete frame, the connection is closed above it.
+		MaxReadBuffer uint
+		// Loops is the number of event loops, each with its own epoll fd and connection table.
+		// Zero means GOMAXPROCS.
+		Loops uint
+		// Balance spreads accepted connections across loops: BalanceRoundRobin (default) or BalanceLeastConns.
+		Balance string
+		// Mode is the epoll trigger mode: ModeLevel (default), ModeEdge or ModeOneShot.
+		// In edge and oneshot modes the connection stays registered while it is handled,
+		// its socket is read until EAGAIN and it must implement syscall.Conn.
+		Mode string
+		// WriteHighWater pauses reads from a connection while its write queue is at or above it,
+		// reads resume when the queue is flushed below. Zero means 1 MiB.
+		WriteHighWater uint
+		// MaxWriteBuffer limits the write queue of a connection, the connection is closed above it.
+		// Zero means 4 times WriteHighWater.
+		MaxWriteBuffer uint
+		// Workers is the number of handler goroutines of every loop, ready connections wait
+		// for them in a queue of QueueSize (CountEvents if zero). Zero Workers means
+		// a goroutine per ready connection.
+		Workers   uint
+		QueueSize uint
+		// QueuePolicy applies when the queue is full: QueueBlock (default) stops the loop,
+		// QueueDrop skips the event and QueueClose closes the connection.
+		QueuePolicy string
+		Metrics     Metrics
+		// OnClose is called once when a connection is closed and removed from its loop,
+		// by the peer, the handler or the stop of the loop.
+		OnClose func(c TConnect)
+		// Backend waits for readiness: BackendEpoll (default) or the experimental
+		// BackendIOUringPoll, which falls back to epoll when the kernel does not provide
+		// or allow io_uring, see TEpoll.Backend. Both only wait, the sockets are
+		// accepted, read and written by syscalls.
+		Backend string
+	}
+
+	// Metrics receives the dispatcher measurements from the loop and the handler goroutines.
+	Metrics interface {
+		// QueueDepth is reported when a connection is queued or taken from the queue.
+		QueueDepth(depth int)
+		// HandleLatency is the time from the readiness event to the end of handling.
+		HandleLatency(d time.Duration)
+		// Rejected is reported when a full queue drops or closes a connection.
+		Rejected(policy string)
 	}
+
+	// Decoder is compatible with framing.Codec and bufio.SplitFunc.
+	Decoder interface {
+		Decode(data []byte, atEOF bool) (advance int, frame []byte, err error)
+	}
+)
+
+const (
+	BalanceRoundRobin = "round_robin"
+	BalanceLeastConns = "least_conns"
+)
+
+const (
+	BackendEpoll = "epoll"
+	// BackendIOUringPoll is experimental, it waits with io_uring poll requests instead
+	// of epoll_wait. Accepts, reads and writes are not submitted to the ring, so it does
+	// not save syscalls: level mode makes more of them than epoll, a poll(2) recheck
+	// after every wait and a poll request per handled event.
+	BackendIOUringPoll = "io_uring_poll"
+)
+
+const (
+	QueueBlock = "block"
+	QueueDrop  = "drop"
+	QueueClose = "close"
+)
+
+const (
+	// ModeLevel keeps the connection registered while it is handled, the events
+	// which come during handling are skipped by its busy state.
+	ModeLevel = "level"
+	// ModeEdge registers the connection with EPOLLET, events during handling trigger one more drain.
+	ModeEdge = "edge"
+	// ModeOneShot registers the connection with EPOLLONESHOT and rearms it after handling.
+	ModeOneShot = "oneshot"
+)
+
+const (
+	defaultMaxReadBuffer  = 4 << 20
+	defaultWriteHighWater = 1 << 20
 )
 
 func (c Option) Validate() error {
@@ -29,5 +121,81 @@ func (c Option) Validate() error {
 	if c.WaitIntervalMS == 0 {
 		return fmt.Errorf("epoll wait interval is empty")
 	}
+	switch c.Balance {
+	case "", BalanceRoundRobin, BalanceLeastConns:
+	default:
+		return fmt.Errorf("epoll balance must be %s or %s", BalanceRoundRobin, BalanceLeastConns)
+	}
+	if c.MaxWriteBuffer > 0 && int(c.MaxWriteBuffer) < c.writeHighWater() {
+		return fmt.Errorf("epoll max write buffer is less than write high water %d", c.writeHighWater())
+	}
+	switch c.QueuePolicy {
+	case "", QueueBlock, QueueDrop, QueueClose:
+	default:
+		return fmt.Errorf("epoll queue policy must be %s, %s or %s", QueueBlock, QueueDrop, QueueClose)
+	}
+	switch c.Backend {
+	case "", BackendEpoll, BackendIOUringPoll:
+	default:
+		return fmt.Errorf("epoll backend must be %s or %s", BackendEpoll, BackendIOUringPoll)
+	}
+	switch c.Mode {
+	case "", ModeLevel, ModeEdge, ModeOneShot:
+	default:
+		return fmt.Errorf("epoll mode must be %s, %s or %s", ModeLevel, ModeEdge, ModeOneShot)
+	}
 	return nil
 }
+
+func (c Option) events() uint32 {
+	switch c.Mode {
+	case ModeEdge:
+		return epollEvents | unix.EPOLLET
+	case ModeOneShot:
+		return epollEvents | unix.EPOLLONESHOT
+	default:
+		return epollEvents
+	}
+}
+
+func (c Option) registered() bool {
+	return c.Mode == ModeEdge || c.Mode == ModeOneShot
+}
+
+func (c Option) loops() int {
+	if c.Loops > 0 {
+		return int(c.Loops)
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+func (c Option) maxReadBuffer() int {
+	if c.MaxReadBuffer > 0 {
+		return int(c.MaxReadBuffer)
+	}
+	if b, ok := c.Decoder.(interface{ BufferSize() int }); ok {
+		return b.BufferSize()
+	}
+	return defaultMaxReadBuffer
+}
+
+func (c Option) writeHighWater() int {
+	if c.WriteHighWater > 0 {
+		return int(c.WriteHighWater)
+	}
+	return defaultWriteHighWater
+}
+
+func (c Option) maxWriteBuffer() int {
+	if c.MaxWriteBuffer > 0 {
+		return int(c.MaxWriteBuffer)
+	}
+	return 4 * c.writeHighWater()
+}
+
+func (c Option) queueSize() int {
+	if c.Workers > 0 && c.QueueSize > 0 {
+		return int(c.QueueSize)
+	}
+	return int(c.CountEvents)
+}
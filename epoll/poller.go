@@ -0,0 +1,77 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package epoll
+
+import (
+	"syscall"
+
+	"go.osspkg.com/errors"
+	"go.osspkg.com/logx"
+	"golang.org/x/sys/unix"
+)
+
+// poller is the readiness backend of a loop, the events and the result
+// are in the epoll format for every backend.
+type poller interface {
+	add(fd int32, events uint32) error
+	mod(fd int32, events uint32) error
+	del(fd int32) error
+	wait(events []unix.EpollEvent, msec int) (int, error)
+	close() error
+	// backend is BackendEpoll or BackendIOUringPoll.
+	backend() string
+}
+
+func newPoller(c Option) (poller, error) {
+	if c.Backend == BackendIOUringPoll {
+		p, err := newUring(c)
+		if err == nil {
+			return p, nil
+		}
+		logx.Warn("Epoll io_uring is unavailable, fallback to epoll", "err", err)
+	}
+	return newEpoll()
+}
+
+type _epollPoller struct {
+	fd int
+}
+
+func newEpoll() (*_epollPoller, error) {
+	fd, err := unix.EpollCreate1(unix.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	return &_epollPoller{fd: fd}, nil
+}
+
+func (v *_epollPoller) add(fd int32, events uint32) error {
+	return unix.EpollCtl(v.fd, syscall.EPOLL_CTL_ADD, int(fd), &unix.EpollEvent{Events: events, Fd: fd})
+}
+
+func (v *_epollPoller) mod(fd int32, events uint32) error {
+	return unix.EpollCtl(v.fd, syscall.EPOLL_CTL_MOD, int(fd), &unix.EpollEvent{Events: events, Fd: fd})
+}
+
+func (v *_epollPoller) del(fd int32) error {
+	return unix.EpollCtl(v.fd, syscall.EPOLL_CTL_DEL, int(fd), nil)
+}
+
+func (v *_epollPoller) wait(events []unix.EpollEvent, msec int) (int, error) {
+	n, err := unix.EpollWait(v.fd, events, msec)
+	if err != nil && !errors.Is(err, unix.EINTR) {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (v *_epollPoller) close() error {
+	return unix.Close(v.fd)
+}
+
+func (v *_epollPoller) backend() string {
+	return BackendEpoll
+}
@@ -0,0 +1,119 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package epoll
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Registry gives access to the served connections for unsolicited writes.
+// Writes go through the connection write queue and are safe to call
+// concurrently with the handler.
+type Registry interface {
+	// Lookup returns the connection by TConnect.ID.
+	Lookup(id uint64) (TConnect, bool)
+	// Range calls fn for every connection until it returns false,
+	// fn may write to or close the connection.
+	Range(fn func(c TConnect) bool)
+	// Send queues p for the connection by id.
+	Send(id uint64, p []byte) error
+	// Broadcast queues p for all connections or, if tags are given, for the connections
+	// having any of them, and returns the number of connections it is queued for.
+	Broadcast(p []byte, tags ...string) int
+}
+
+var connID atomic.Uint64
+
+func nextConnID() uint64 {
+	return connID.Add(1)
+}
+
+func (v *_epoll) Lookup(id uint64) (TConnect, bool) {
+	v.mux.RLock()
+	defer v.mux.RUnlock()
+
+	c, ok := v.ids[id]
+	return c, ok
+}
+
+func (v *_epoll) Range(fn func(c TConnect) bool) {
+	v.mux.RLock()
+	list := make([]*connect, 0, len(v.conn))
+	for _, c := range v.conn {
+		list = append(list, c)
+	}
+	v.mux.RUnlock()
+
+	for _, c := range list {
+		if !fn(c) {
+			return
+		}
+	}
+}
+
+func (v *_epoll) Send(id uint64, p []byte) error {
+	return send(v, id, p)
+}
+
+func (v *_epoll) Broadcast(p []byte, tags ...string) int {
+	return broadcast(v, p, tags...)
+}
+
+// registries joins the registries of several loops, connection ids are unique across them.
+type registries []Registry
+
+func (v registries) Lookup(id uint64) (TConnect, bool) {
+	for _, r := range v {
+		if c, ok := r.Lookup(id); ok {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+func (v registries) Range(fn func(c TConnect) bool) {
+	next := true
+	for _, r := range v {
+		r.Range(func(c TConnect) bool {
+			next = fn(c)
+			return next
+		})
+		if !next {
+			return
+		}
+	}
+}
+
+func (v registries) Send(id uint64, p []byte) error {
+	return send(v, id, p)
+}
+
+func (v registries) Broadcast(p []byte, tags ...string) int {
+	return broadcast(v, p, tags...)
+}
+
+func send(r Registry, id uint64, p []byte) error {
+	c, ok := r.Lookup(id)
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrConnNotFound, id)
+	}
+	_, err := c.Write(p)
+	return err
+}
+
+func broadcast(r Registry, p []byte, tags ...string) (n int) {
+	r.Range(func(c TConnect) bool {
+		if len(tags) > 0 && !c.HasTag(tags...) {
+			return true
+		}
+		if _, err := c.Write(p); err == nil {
+			n++
+		}
+		return true
+	})
+	return
+}
@@ -0,0 +1,231 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package epoll
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	"go.osspkg.com/errors"
+	"go.osspkg.com/logx"
+	"go.osspkg.com/syncing"
+	"go.osspkg.com/xc"
+)
+
+type (
+	// stream serves the connections of stream listeners by epoll loops,
+	// it is shared by ServerTCP and ServerUnix.
+	stream struct {
+		wg               syncing.Group
+		shards           []shard
+		tls              *tls.Config
+		handshakeTimeout time.Duration
+		mux              sync.RWMutex
+		// stopped is set by StopAccept, the listeners are closed on purpose.
+		stopped bool
+	}
+
+	shard struct {
+		listener net.Listener
+		epoll    TEpoll
+	}
+)
+
+func (s *stream) registry() registries {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	list := make(registries, 0, len(s.shards))
+	for i := range s.shards {
+		list = append(list, s.shards[i].epoll)
+	}
+	return list
+}
+
+func (s *stream) Lookup(id uint64) (TConnect, bool) {
+	return s.registry().Lookup(id)
+}
+
+func (s *stream) Range(fn func(c TConnect) bool) {
+	s.registry().Range(fn)
+}
+
+func (s *stream) Send(id uint64, p []byte) error {
+	return s.registry().Send(id, p)
+}
+
+func (s *stream) Broadcast(p []byte, tags ...string) int {
+	return s.registry().Broadcast(p, tags...)
+}
+
+// setup creates count epoll instances, a listener is assigned to each of them later.
+func (s *stream) setup(opt Option, count int) (err error) {
+	s.wg = syncing.NewGroup()
+	shards := make([]shard, 0, count)
+	defer func() {
+		if err != nil {
+			for _, sh := range shards {
+				err = errors.Wrap(err, release(sh.epoll))
+			}
+		}
+	}()
+	for i := 0; i < count; i++ {
+		ep, e := New(opt)
+		if e != nil {
+			return e
+		}
+		shards = append(shards, shard{epoll: ep})
+	}
+
+	s.mux.Lock()
+	s.shards = shards
+	s.mux.Unlock()
+	return nil
+}
+
+// release closes the epoll instances when the listeners fail, they are not listened yet.
+func (s *stream) release() (err error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	for _, sh := range s.shards {
+		err = errors.Wrap(err, release(sh.epoll))
+	}
+	return
+}
+
+// release closes the poller of an epoll instance of New which is not listened.
+func release(ep TEpoll) error {
+	if r, ok := ep.(interface{ release() error }); ok {
+		return r.release()
+	}
+	return nil
+}
+
+// StopAccept closes the listeners, the accepted connections are served
+// until ctx of ListenAndServe is closed. It is the start of a graceful stop.
+func (s *stream) StopAccept() error {
+	s.mux.Lock()
+	s.stopped = true
+	s.mux.Unlock()
+	return s.closeListeners()
+}
+
+// setListener assigns the listener of the shard, it is closed at once after StopAccept.
+func (s *stream) setListener(i int, l net.Listener) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.shards[i].listener = l
+	if s.stopped {
+		l.Close() // nolint: errcheck
+	}
+}
+
+func (s *stream) acceptStopped() bool {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.stopped
+}
+
+func (s *stream) closeListeners() (err error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	for _, sh := range s.shards {
+		if sh.listener == nil {
+			continue
+		}
+		if e := sh.listener.Close(); e != nil && !errors.Is(e, net.ErrClosed) {
+			err = errors.Wrap(err, e)
+		}
+	}
+	return
+}
+
+func (s *stream) serve(ctx xc.Context, addr string) {
+	s.mux.RLock()
+	shards := s.shards
+	s.mux.RUnlock()
+
+	for _, sh := range shards {
+		sh := sh
+		s.wg.Background(func() {
+			s.connAccept(ctx, sh)
+		})
+		s.wg.Background(func() {
+			s.epollListen(ctx, sh.epoll)
+		})
+	}
+	s.wg.Background(func() {
+		<-ctx.Done()
+		if e := s.closeListeners(); e != nil {
+			logx.Error("Epoll close listener", "err", e)
+		}
+	})
+	logx.Info("Epoll server started", "ip", addr, "listeners", len(shards))
+	s.wg.Wait()
+}
+
+func (s *stream) connAccept(ctx xc.Context, sh shard) {
+	for {
+		conn, err := sh.listener.Accept()
+		if err != nil {
+			if s.acceptStopped() {
+				// the loops keep serving the accepted connections
+				return
+			}
+			select {
+			case <-ctx.Done():
+			default:
+				logx.Error("Epoll conn accept", "err", err)
+			}
+			ctx.Close()
+			return
+		}
+		if s.tls != nil {
+			s.wg.Background(func() {
+				s.handshake(ctx, sh, conn)
+			})
+			continue
+		}
+		if err = sh.epoll.Accept(conn); err != nil {
+			logx.Error("Epoll append connect", "err", err, "ip", conn.RemoteAddr())
+		}
+	}
+}
+
+// handshake runs off the event loop, the loop only gets established TLS connections.
+func (s *stream) handshake(ctx xc.Context, sh shard, conn net.Conn) {
+	tc := tls.Server(newSockConn(conn), s.tls)
+
+	hctx, cancel := context.WithTimeout(ctx.Context(), s.handshakeTimeout)
+	defer cancel()
+
+	if err := tc.HandshakeContext(hctx); err != nil {
+		logx.Warn("Epoll tls handshake", "err", err, "ip", conn.RemoteAddr())
+		if err = conn.Close(); err != nil {
+			logx.Error("Epoll close connect", "err", err, "ip", conn.RemoteAddr())
+		}
+		return
+	}
+	if err := sh.epoll.Accept(tc); err != nil {
+		logx.Error("Epoll append connect", "err", err, "ip", conn.RemoteAddr())
+	}
+}
+
+func (s *stream) epollListen(ctx xc.Context, ep TEpoll) {
+	defer func() {
+		ctx.Close()
+	}()
+
+	if err := ep.Listen(ctx.Context()); err != nil {
+		logx.Error("Epoll listen connects", "err", err)
+	}
+}
@@ -10,14 +10,16 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"syscall"
 	"time"
 
 	"go.osspkg.com/errors"
 	"go.osspkg.com/logx"
-	"go.osspkg.com/syncing"
 	"go.osspkg.com/xc"
+	"golang.org/x/sys/unix"
 
 	"go.osspkg.com/network/address"
+	"go.osspkg.com/network/listen"
 )
 
 type (
@@ -29,22 +31,47 @@ type (
 		ShutdownTimeout time.Duration `yaml:"shutdown_timeout,omitempty"`
 		CountEvents     uint          `yaml:"count_events,omitempty"`
 		WaitIntervalMS  uint          `yaml:"wait_interval_ms,omitempty"`
+		MaxReadBuffer   uint          `yaml:"max_read_buffer,omitempty"`
+		// Loops is the number of event loops, zero means GOMAXPROCS.
+		Loops uint `yaml:"loops,omitempty"`
+		// Balance is round_robin (default) or least_conns.
+		Balance string `yaml:"balance,omitempty"`
+		// Mode is level (default), edge or oneshot.
+		Mode           string `yaml:"mode,omitempty"`
+		WriteHighWater uint   `yaml:"write_high_water,omitempty"`
+		MaxWriteBuffer uint   `yaml:"max_write_buffer,omitempty"`
+		// Workers, QueueSize and QueuePolicy bound the handler goroutines of every loop.
+		Workers     uint   `yaml:"workers,omitempty"`
+		QueueSize   uint   `yaml:"queue_size,omitempty"`
+		QueuePolicy string `yaml:"queue_policy,omitempty"`
+		// Backend is epoll (default) or the experimental io_uring_poll with the fallback to epoll.
+		Backend string `yaml:"backend,omitempty"`
+		// SSL enables TLS, the handshake is done before the connection is passed to a loop.
+		SSL              *listen.SSL   `yaml:"ssl,omitempty"`
+		HandshakeTimeout time.Duration `yaml:"handshake_timeout,omitempty"`
+		// ReusePort opens a listener with SO_REUSEPORT per loop, so the kernel
+		// spreads new connections and every loop accepts its own.
+		ReusePort bool `yaml:"reuse_port,omitempty"`
 	}
 
 	ServerTCP struct {
-		wg       syncing.Group
-		Handler  func(ctx context.Context, w io.Writer, r io.Reader) error
-		Config   ConfigTCP
-		listener net.Listener
-		epoll    TEpoll
+		stream
+		Handler func(ctx context.Context, w io.Writer, r io.Reader) error
+		Decoder Decoder
+		Metrics Metrics
+		// OnClose is called once when a connection is closed, see Option.
+		OnClose func(c TConnect)
+		Config  ConfigTCP
 	}
 )
 
-func (s *ServerTCP) init() (err error) {
+// ServerTCP is a Registry of the connections of all its loops.
+var _ Registry = (*ServerTCP)(nil)
+
+func (s *ServerTCP) init() error {
 	if s.Handler == nil {
 		return fmt.Errorf("epoll tcp: handler is empty")
 	}
-	s.wg = syncing.NewGroup()
 	s.Config.Addr = address.ResolveIPPort(s.Config.Addr)
 	if s.Config.CountEvents == 0 {
 		s.Config.CountEvents = 100
@@ -52,67 +79,83 @@ func (s *ServerTCP) init() (err error) {
 	if s.Config.WaitIntervalMS == 0 {
 		s.Config.WaitIntervalMS = 500
 	}
-	s.epoll, err = New(Option{
+	if s.Config.SSL != nil && len(s.Config.SSL.Certs) > 0 {
+		conf, err := listen.NewTLSConfig(s.Config.SSL)
+		if err != nil {
+			return fmt.Errorf("epoll tcp: %w", err)
+		}
+		if s.Config.HandshakeTimeout == 0 {
+			s.Config.HandshakeTimeout = 10 * time.Second
+		}
+		s.tls, s.handshakeTimeout = conf, s.Config.HandshakeTimeout
+	}
+	opt := Option{
 		Handler:        s.Handler,
 		CountEvents:    s.Config.CountEvents,
 		WaitIntervalMS: s.Config.WaitIntervalMS,
-	})
-	return
-}
-
-func (s *ServerTCP) ListenAndServe(ctx xc.Context) (err error) {
-	defer func() {
-		ctx.Close()
-		logx.Error("Epoll server stopped", "err", err, "ip", s.Config.Addr)
-	}()
-
-	if err = s.init(); err != nil {
-		return
+		Decoder:        s.Decoder,
+		MaxReadBuffer:  s.Config.MaxReadBuffer,
+		Loops:          s.Config.Loops,
+		Balance:        s.Config.Balance,
+		Mode:           s.Config.Mode,
+		WriteHighWater: s.Config.WriteHighWater,
+		MaxWriteBuffer: s.Config.MaxWriteBuffer,
+		Workers:        s.Config.Workers,
+		QueueSize:      s.Config.QueueSize,
+		QueuePolicy:    s.Config.QueuePolicy,
+		Backend:        s.Config.Backend,
+		Metrics:        s.Metrics,
+		OnClose:        s.OnClose,
 	}
-	if s.listener, err = net.Listen("tcp", s.Config.Addr); err != nil {
-		return
+	count := 1
+	if s.Config.ReusePort {
+		count, opt.Loops = opt.loops(), 1
 	}
-	defer func() {
-		err = errors.Wrap(err, s.listener.Close())
-	}()
-	s.wg.Background(func() {
-		s.connAccept(ctx)
-	})
-	s.wg.Background(func() {
-		s.epollListen(ctx)
-	})
-	logx.Info("Epoll server started", "ip", s.Config.Addr)
-	s.wg.Wait()
-	return
+	return s.setup(opt, count)
 }
 
-func (s *ServerTCP) connAccept(ctx xc.Context) {
-	defer func() {
-		ctx.Close()
-	}()
-	for {
-		conn, err := s.listener.Accept()
-		if err != nil {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				logx.Error("Epoll conn accept", "err", err)
-				return
+func (s *ServerTCP) listen(ctx context.Context) error {
+	lc := net.ListenConfig{}
+	if s.Config.ReusePort {
+		lc.Control = func(_, _ string, rc syscall.RawConn) (err error) {
+			if e := rc.Control(func(fd uintptr) {
+				err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+			}); e != nil {
+				return e
 			}
+			return
+		}
+	}
+	for i := range s.shards {
+		l, err := lc.Listen(ctx, "tcp", s.Config.Addr)
+		if err != nil {
+			return err
 		}
-		if err = s.epoll.Accept(conn); err != nil {
-			logx.Error("Epoll append connect", "err", err, "ip", conn.RemoteAddr())
+		s.setListener(i, l)
+		if i == 0 {
+			// the port may be dynamic, the next listeners must join the same one
+			s.Config.Addr = l.Addr().String()
 		}
 	}
+	return nil
 }
 
-func (s *ServerTCP) epollListen(ctx xc.Context) {
+func (s *ServerTCP) ListenAndServe(ctx xc.Context) (err error) {
 	defer func() {
 		ctx.Close()
+		logx.Error("Epoll server stopped", "err", err, "ip", s.Config.Addr)
 	}()
 
-	if err := s.epoll.Listen(ctx.Context()); err != nil {
-		logx.Error("Epoll listen connects", "err", err)
+	if err = s.init(); err != nil {
+		return
 	}
+	defer func() {
+		err = errors.Wrap(err, s.closeListeners())
+	}()
+	if err = s.listen(ctx.Context()); err != nil {
+		err = errors.Wrap(err, s.release())
+		return
+	}
+	s.serve(ctx, s.Config.Addr)
+	return
 }
@@ -0,0 +1,143 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package epoll_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"go.osspkg.com/casecheck"
+	"go.osspkg.com/xc"
+
+	"go.osspkg.com/network/epoll"
+	"go.osspkg.com/network/framing"
+	"go.osspkg.com/network/listen"
+)
+
+func TestUnit_ServerTCP_TLS(t *testing.T) {
+	cert, key := selfSigned(t)
+	codec := framing.NewUint16(0)
+
+	for _, mode := range []string{epoll.ModeLevel, epoll.ModeEdge} {
+		t.Run(mode, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			casecheck.NoError(t, err)
+			addr := l.Addr().String()
+			casecheck.NoError(t, l.Close())
+
+			srv := &epoll.ServerTCP{
+				Handler: framing.FrameHandler(codec, func(_ context.Context, msg []byte) ([]byte, error) {
+					return append([]byte(">> "), msg...), nil
+				}),
+				Decoder: codec,
+				Config: epoll.ConfigTCP{
+					Addr:           addr,
+					WaitIntervalMS: 10,
+					Loops:          1,
+					Mode:           mode,
+					SSL: &listen.SSL{Certs: []listen.Certificate{
+						{CertData: cert, KeyData: key},
+					}},
+				},
+			}
+			ctx := xc.New()
+			done := make(chan error)
+			go func() { done <- srv.ListenAndServe(ctx) }()
+
+			var conn *tls.Conn
+			for i := 0; i < 50; i++ {
+				if conn, err = tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true}); err == nil {
+					break
+				}
+				time.Sleep(20 * time.Millisecond)
+			}
+			casecheck.NoError(t, err)
+			defer conn.Close() // nolint: errcheck
+
+			// the first frames go out right behind the handshake
+			w, r := framing.NewWriter(conn, codec), framing.NewReader(conn, codec)
+			casecheck.NoError(t, w.WriteFrame([]byte("hello")))
+			casecheck.NoError(t, w.WriteFrame(make([]byte, 20000)))
+
+			casecheck.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+			got, err := r.ReadFrame()
+			casecheck.NoError(t, err)
+			casecheck.Equal(t, ">> hello", string(got))
+			got, err = r.ReadFrame()
+			casecheck.NoError(t, err)
+			casecheck.Equal(t, 20003, len(got))
+
+			casecheck.NoError(t, w.WriteFrame([]byte("world")))
+			got, err = r.ReadFrame()
+			casecheck.NoError(t, err)
+			casecheck.Equal(t, ">> world", string(got))
+
+			ctx.Close()
+			<-done
+		})
+	}
+}
+
+func selfSigned(t *testing.T) (cert, key []byte) {
+	pk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	casecheck.NoError(t, err)
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &pk.PublicKey, pk)
+	casecheck.NoError(t, err)
+	kder, err := x509.MarshalPKCS8PrivateKey(pk)
+	casecheck.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: kder})
+}
+
+// TestUnit_ServerTCPListenFailed closes the loops of a server which fails to listen.
+func TestUnit_ServerTCPListenFailed(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	casecheck.NoError(t, err)
+	defer busy.Close() // nolint: errcheck
+
+	openFDs := func() int {
+		list, err := os.ReadDir("/proc/self/fd")
+		casecheck.NoError(t, err)
+		return len(list)
+	}
+	before := openFDs()
+	for _, backend := range []string{epoll.BackendEpoll, epoll.BackendIOUringPoll} {
+		for _, reusePort := range []bool{false, true} {
+			srv := &epoll.ServerTCP{
+				Handler: func(context.Context, io.Writer, io.Reader) error { return nil },
+				Config: epoll.ConfigTCP{
+					Addr:      busy.Addr().String(),
+					Loops:     4,
+					Backend:   backend,
+					ReusePort: reusePort,
+				},
+			}
+			casecheck.Error(t, srv.ListenAndServe(xc.New()))
+		}
+	}
+	casecheck.Equal(t, before, openFDs())
+}
@@ -0,0 +1,278 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package epoll_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.osspkg.com/casecheck"
+	"go.osspkg.com/xc"
+
+	"go.osspkg.com/network/epoll"
+	"go.osspkg.com/network/framing"
+	"go.osspkg.com/network/listen"
+)
+
+func TestUnit_ServerUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "epoll.sock")
+	// the socket file of a stopped process is removed, of a live one is an error
+	stale, err := net.Listen("unix", path)
+	casecheck.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	codec := framing.NewDelimiter([]byte("\n"), 64)
+	newServer := func() *epoll.ServerUnix {
+		return &epoll.ServerUnix{
+			Handler: framing.FrameHandler(codec, func(_ context.Context, msg []byte) ([]byte, error) {
+				return append([]byte(">> "), msg...), nil
+			}),
+			Decoder: codec,
+			Config: epoll.ConfigUnix{
+				Addr:           path,
+				WaitIntervalMS: 10,
+				Loops:          2,
+				Unix:           &listen.Unix{Mode: "0600"},
+			},
+		}
+	}
+	casecheck.Error(t, newServer().ListenAndServe(xc.New()))
+	casecheck.NoError(t, stale.Close())
+
+	srv := newServer()
+	ctx := xc.New()
+	done := make(chan error)
+	go func() { done <- srv.ListenAndServe(ctx) }()
+
+	conn := dialRetry(t, "unix", path)
+	defer conn.Close() // nolint: errcheck
+	fi, err := os.Stat(path)
+	casecheck.NoError(t, err)
+	casecheck.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	_, err = conn.Write([]byte("hello\n"))
+	casecheck.NoError(t, err)
+	casecheck.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	got, err := bufio.NewReader(conn).ReadString('\n')
+	casecheck.NoError(t, err)
+	casecheck.Equal(t, ">> hello\n", got)
+
+	count := 0
+	srv.Range(func(epoll.TConnect) bool {
+		count++
+		return true
+	})
+	casecheck.Equal(t, 1, count)
+
+	ctx.Close()
+	<-done
+	_, err = os.Stat(path)
+	casecheck.True(t, os.IsNotExist(err), err)
+}
+
+func TestUnit_ServerUDP(t *testing.T) {
+	l, err := net.ListenPacket("udp", "127.0.0.1:0")
+	casecheck.NoError(t, err)
+	addr := l.LocalAddr().String()
+	casecheck.NoError(t, l.Close())
+
+	srv := &epoll.ServerUDP{
+		Handler: func(_ context.Context, w io.Writer, r io.Reader) error {
+			b, e := io.ReadAll(r)
+			if e != nil {
+				return e
+			}
+			from := w.(interface{ RemoteAddr() net.Addr }).RemoteAddr()
+			_, e = w.Write(append(b, " from "+from.String()...))
+			return e
+		},
+		Config: epoll.ConfigUDP{Addr: addr, WaitIntervalMS: 10},
+	}
+	ctx := xc.New()
+	done := make(chan error)
+	go func() { done <- srv.ListenAndServe(ctx) }()
+
+	conn, err := net.Dial("udp", addr)
+	casecheck.NoError(t, err)
+	defer conn.Close() // nolint: errcheck
+
+	buf := make([]byte, 1024)
+	for i := 0; i < 50; i++ {
+		_, err = conn.Write([]byte("ping"))
+		casecheck.NoError(t, err)
+		casecheck.NoError(t, conn.SetReadDeadline(time.Now().Add(100*time.Millisecond)))
+		var n int
+		if n, err = conn.Read(buf); err == nil {
+			casecheck.Equal(t, "ping from "+conn.LocalAddr().String(), string(buf[:n]))
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	casecheck.NoError(t, err)
+
+	ctx.Close()
+	casecheck.NoError(t, <-done)
+}
+
+func TestUnit_ServerUDPLimits(t *testing.T) {
+	l, err := net.ListenPacket("udp", "127.0.0.1:0")
+	casecheck.NoError(t, err)
+	addr := l.LocalAddr().String()
+	casecheck.NoError(t, l.Close())
+
+	var (
+		active, peak, handled atomic.Int32
+		release               = make(chan struct{})
+	)
+	srv := &epoll.ServerUDP{
+		Handler: func(_ context.Context, w io.Writer, r io.Reader) error {
+			if n := active.Add(1); n > peak.Load() {
+				peak.Store(n)
+			}
+			defer active.Add(-1)
+			<-release
+
+			b, e := io.ReadAll(r)
+			if e != nil {
+				return e
+			}
+			handled.Add(1)
+			_, e = w.Write(b)
+			return e
+		},
+		Config: epoll.ConfigUDP{Addr: addr, WaitIntervalMS: 10, MaxPacketSize: 16, Workers: 2},
+	}
+	ctx := xc.New()
+	done := make(chan error)
+	go func() { done <- srv.ListenAndServe(ctx) }()
+
+	conn, err := net.Dial("udp", addr)
+	casecheck.NoError(t, err)
+	defer conn.Close() // nolint: errcheck
+
+	// the server is ready when it handles the first datagram
+	for active.Load() == 0 {
+		conn.Write([]byte("ping")) // nolint: errcheck
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	_, err = conn.Write([]byte(strings.Repeat("x", 32)))
+	casecheck.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		_, err = conn.Write([]byte("ping"))
+		casecheck.NoError(t, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	casecheck.Equal(t, int32(2), peak.Load())
+	close(release)
+
+	buf := make([]byte, 64)
+	for {
+		casecheck.NoError(t, conn.SetReadDeadline(time.Now().Add(200*time.Millisecond)))
+		n, e := conn.Read(buf)
+		if e != nil {
+			break
+		}
+		// the long datagram is dropped, not truncated to 16 bytes
+		casecheck.Equal(t, "ping", string(buf[:n]))
+	}
+	casecheck.True(t, handled.Load() >= 11, handled.Load())
+
+	ctx.Close()
+	casecheck.NoError(t, <-done)
+}
+
+// TestUnit_ServerUDPShutdown waits for the queued datagrams and cancels a blocked handler
+// when the shutdown is out of time.
+func TestUnit_ServerUDPShutdown(t *testing.T) {
+	l, err := net.ListenPacket("udp", "127.0.0.1:0")
+	casecheck.NoError(t, err)
+	addr := l.LocalAddr().String()
+	casecheck.NoError(t, l.Close())
+
+	var (
+		handled   atomic.Int32
+		started   = make(chan struct{}, 1)
+		cancelled = make(chan struct{})
+	)
+	srv := &epoll.ServerUDP{
+		Handler: func(ctx context.Context, _ io.Writer, r io.Reader) error {
+			b, e := io.ReadAll(r)
+			if e != nil {
+				return e
+			}
+			if string(b) == "block" {
+				started <- struct{}{}
+				<-ctx.Done()
+				close(cancelled)
+				return nil
+			}
+			handled.Add(1)
+			return nil
+		},
+		Config: epoll.ConfigUDP{Addr: addr, WaitIntervalMS: 10, Workers: 1},
+	}
+	ctx := xc.New()
+	done := make(chan error)
+	go func() { done <- srv.ListenAndServe(ctx) }()
+
+	conn, err := net.Dial("udp", addr)
+	casecheck.NoError(t, err)
+	defer conn.Close() // nolint: errcheck
+	// the datagrams are lost or refused until the server listens
+	for handled.Load() == 0 {
+		conn.Write([]byte("ping")) // nolint: errcheck
+		time.Sleep(10 * time.Millisecond)
+	}
+	_, err = conn.Write([]byte("block"))
+	casecheck.NoError(t, err)
+	<-started
+	// the only worker is blocked, the datagram waits in the queue
+	_, err = conn.Write([]byte("queued"))
+	casecheck.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	before := handled.Load()
+
+	sctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = srv.Shutdown(sctx)
+	casecheck.True(t, errors.Is(err, context.DeadlineExceeded), err)
+	<-cancelled
+	// the queue is dropped after the deadline
+	casecheck.Equal(t, before, handled.Load())
+
+	// the server serves until ctx is closed
+	select {
+	case err = <-done:
+		t.Fatalf("stopped by shutdown: %v", err)
+	default:
+	}
+	ctx.Close()
+	casecheck.NoError(t, <-done)
+}
+
+func dialRetry(t *testing.T, network, addr string) net.Conn {
+	var (
+		conn net.Conn
+		err  error
+	)
+	for i := 0; i < 50; i++ {
+		if conn, err = net.Dial(network, addr); err == nil {
+			return conn
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	casecheck.NoError(t, err)
+	return nil
+}
@@ -0,0 +1,336 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package epoll
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"runtime"
+	"sync"
+	"syscall"
+
+	"go.osspkg.com/errors"
+	"go.osspkg.com/ioutils/data"
+	"go.osspkg.com/logx"
+	"go.osspkg.com/syncing"
+	"go.osspkg.com/xc"
+	"golang.org/x/sys/unix"
+
+	"go.osspkg.com/network/address"
+	netfd "go.osspkg.com/network/fd"
+	"go.osspkg.com/network/internal"
+)
+
+// defaultUDPQueueSize is the number of datagrams waiting for the workers.
+const defaultUDPQueueSize = 1024
+
+type (
+	ConfigUDP struct {
+		Addr           string `yaml:"addr"`
+		WaitIntervalMS uint   `yaml:"wait_interval_ms,omitempty"`
+		// MaxPacketSize is the read buffer for one datagram, a longer one is dropped.
+		MaxPacketSize uint `yaml:"max_packet_size,omitempty"`
+		// Workers is the number of handler goroutines, zero means GOMAXPROCS. Datagrams
+		// wait for them in a queue of QueueSize (1024 if zero).
+		Workers   uint `yaml:"workers,omitempty"`
+		QueueSize uint `yaml:"queue_size,omitempty"`
+		// QueuePolicy applies when the queue is full: QueueBlock (default) stops reading,
+		// so the kernel drops datagrams above the socket buffer, QueueDrop drops the datagram.
+		QueuePolicy string `yaml:"queue_policy,omitempty"`
+	}
+
+	// ServerUDP reads datagrams when the socket is ready, without a goroutine blocked on it.
+	// Handler is called per datagram by the workers, w replies to the sender and has
+	// RemoteAddr() net.Addr. The context of Handler is cancelled when ListenAndServe
+	// stops or Shutdown is out of time.
+	ServerUDP struct {
+		wg      syncing.Group
+		Handler func(ctx context.Context, w io.Writer, r io.Reader) error
+		Config  ConfigUDP
+		conn    *net.UDPConn
+		queue   chan packet
+
+		// once makes the signals, Shutdown may be called before ListenAndServe.
+		once sync.Once
+		// stop is closed by Shutdown, handled when the workers are done.
+		stop, handled chan struct{}
+		halt          func()
+		hctx          context.Context
+		cancel        context.CancelFunc
+	}
+
+	packet struct {
+		addr *net.UDPAddr
+		req  *data.Buffer
+	}
+
+	// packetWriter replies to the sender of a datagram.
+	packetWriter struct {
+		internal.PacketWrite
+	}
+)
+
+func (v *packetWriter) RemoteAddr() net.Addr {
+	return v.Addr
+}
+
+func (s *ServerUDP) signals() {
+	s.once.Do(func() {
+		s.stop, s.handled = make(chan struct{}), make(chan struct{})
+		s.halt = sync.OnceFunc(func() { close(s.stop) })
+		s.hctx, s.cancel = context.WithCancel(context.Background())
+	})
+}
+
+func (s *ServerUDP) init() error {
+	if s.Handler == nil {
+		return fmt.Errorf("epoll udp: handler is empty")
+	}
+	s.wg = syncing.NewGroup()
+	s.Config.Addr = address.ResolveIPPort(s.Config.Addr)
+	if s.Config.WaitIntervalMS == 0 {
+		s.Config.WaitIntervalMS = 500
+	}
+	if s.Config.MaxPacketSize == 0 {
+		s.Config.MaxPacketSize = internal.UDPPacketSize
+	}
+	if s.Config.Workers == 0 {
+		s.Config.Workers = uint(runtime.GOMAXPROCS(0))
+	}
+	if s.Config.QueueSize == 0 {
+		s.Config.QueueSize = defaultUDPQueueSize
+	}
+	switch s.Config.QueuePolicy {
+	case "", QueueBlock, QueueDrop:
+	default:
+		return fmt.Errorf("epoll udp: queue policy must be %s or %s", QueueBlock, QueueDrop)
+	}
+	s.queue = make(chan packet, s.Config.QueueSize)
+	return nil
+}
+
+func (s *ServerUDP) ListenAndServe(ctx xc.Context) (err error) {
+	s.signals()
+	workers := false
+	defer func() {
+		if !workers {
+			close(s.handled)
+		}
+		ctx.Close()
+		logx.Error("Epoll server stopped", "err", err, "ip", s.Config.Addr)
+	}()
+
+	if err = s.init(); err != nil {
+		return
+	}
+	pc, err := (&net.ListenConfig{}).ListenPacket(ctx.Context(), "udp", s.Config.Addr)
+	if err != nil {
+		return
+	}
+	s.conn = pc.(*net.UDPConn)
+	s.Config.Addr = s.conn.LocalAddr().String()
+
+	epfd, err := unix.EpollCreate1(unix.EPOLL_CLOEXEC)
+	if err != nil {
+		return errors.Wrap(err, s.conn.Close())
+	}
+	for i := uint(0); i < s.Config.Workers; i++ {
+		s.wg.Background(func() {
+			s.worker(s.hctx)
+		})
+	}
+	workers = true
+	go func() {
+		s.wg.Wait()
+		close(s.handled)
+	}()
+	closeQueue := sync.OnceFunc(func() { close(s.queue) })
+	defer func() {
+		// the handlers are cancelled and the queued datagrams are dropped,
+		// so the wait is bounded by the handlers which respect their context
+		closeQueue()
+		s.cancel()
+		<-s.handled
+		err = errors.Wrap(err, s.conn.Close(), unix.Close(epfd))
+	}()
+
+	raw, err := s.conn.SyscallConn()
+	if err != nil {
+		return
+	}
+	fd, err := netfd.ByConnect(s.conn)
+	if err != nil {
+		return
+	}
+	if err = unix.EpollCtl(epfd, syscall.EPOLL_CTL_ADD, fd, &unix.EpollEvent{Events: unix.EPOLLIN, Fd: int32(fd)}); err != nil {
+		return
+	}
+
+	logx.Info("Epoll server started", "ip", s.Config.Addr)
+
+	events := make([]unix.EpollEvent, 1)
+	buff := make([]byte, s.Config.MaxPacketSize)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stop:
+			// the queued datagrams are handled, the socket replies until ctx is closed
+			closeQueue()
+			<-ctx.Done()
+			return
+		default:
+		}
+
+		n, e := unix.EpollWait(epfd, events, int(s.Config.WaitIntervalMS))
+		if e != nil && !errors.Is(e, unix.EINTR) {
+			return e
+		}
+		if n <= 0 {
+			continue
+		}
+		if err = s.readPackets(ctx.Context(), raw, buff); err != nil {
+			return
+		}
+	}
+}
+
+// readPackets reads datagrams until EAGAIN and queues them for the workers,
+// the queue is waited outside of the read of the socket.
+func (s *ServerUDP) readPackets(ctx context.Context, raw syscall.RawConn, buff []byte) error {
+	for {
+		p, ok, err := readPacket(raw, buff)
+		if err != nil || !ok {
+			return err
+		}
+		if !s.enqueue(ctx, p) {
+			p.drop()
+		}
+	}
+}
+
+// readPacket copies the next datagram out of the socket, false means EAGAIN.
+// A truncated datagram is dropped.
+func readPacket(raw syscall.RawConn, buff []byte) (p packet, ok bool, err error) {
+	e := raw.Read(func(fd uintptr) bool {
+		for {
+			n, _, flags, from, e := unix.Recvmsg(int(fd), buff, nil, unix.MSG_TRUNC)
+			switch {
+			case e == unix.EINTR:
+				continue
+			case e == unix.EAGAIN:
+				return true
+			case e != nil:
+				err = e
+				return true
+			}
+			addr := udpAddr(from)
+			if addr == nil {
+				continue
+			}
+			if flags&unix.MSG_TRUNC != 0 || n > len(buff) {
+				logx.Warn("Epoll packet is longer than max packet size, dropped",
+					"size", n, "max", len(buff), "ip", addr)
+				continue
+			}
+
+			req := internal.DataPool.Get()
+			if _, err = req.Write(buff[:n]); err != nil {
+				internal.DataPool.Put(req)
+				return true
+			}
+			p, ok = packet{addr: addr, req: req}, true
+			return true
+		}
+	})
+	return p, ok, errors.Wrap(err, e)
+}
+
+// enqueue passes the datagram to the workers by the queue policy,
+// false means it is dropped.
+func (s *ServerUDP) enqueue(ctx context.Context, p packet) bool {
+	if s.Config.QueuePolicy == QueueDrop {
+		select {
+		case s.queue <- p:
+			return true
+		default:
+			logx.Warn("Epoll packet queue is full, dropped", "ip", p.addr)
+			return false
+		}
+	}
+	select {
+	case s.queue <- p:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Shutdown stops reading the datagrams and waits until the queued ones are handled.
+// When ctx is done, it cancels the context of the handlers, drops the rest of the queue
+// and returns ctx.Err(). The socket is closed with ctx of ListenAndServe.
+func (s *ServerUDP) Shutdown(ctx context.Context) error {
+	s.signals()
+	s.halt()
+	select {
+	case <-s.handled:
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		return ctx.Err()
+	}
+}
+
+// worker is one of ConfigUDP.Workers handling queued datagrams,
+// they are dropped when ctx is cancelled.
+func (s *ServerUDP) worker(ctx context.Context) {
+	for p := range s.queue {
+		if ctx.Err() != nil {
+			p.drop()
+			continue
+		}
+		s.handle(ctx, p)
+	}
+}
+
+func (s *ServerUDP) handle(ctx context.Context, p packet) {
+	defer func() {
+		if e := recover(); e != nil {
+			logx.Error("Epoll packet panic", "err", fmt.Errorf("%+v", e), "ip", p.addr)
+		}
+		internal.DataPool.Put(p.req)
+	}()
+
+	w := &packetWriter{PacketWrite: internal.PacketWrite{Addr: p.addr, Conn: s.conn}}
+	if e := s.Handler(ctx, w, p.req); e != nil {
+		logx.Warn("Epoll handling packet", "err", e, "ip", p.addr)
+	}
+}
+
+// drop returns the unread datagram to the pool.
+func (p packet) drop() {
+	p.req.Reset()
+	internal.DataPool.Put(p.req)
+}
+
+func udpAddr(sa unix.Sockaddr) *net.UDPAddr {
+	switch v := sa.(type) {
+	case *unix.SockaddrInet4:
+		return &net.UDPAddr{IP: append(net.IP(nil), v.Addr[:]...), Port: v.Port}
+	case *unix.SockaddrInet6:
+		addr := &net.UDPAddr{IP: append(net.IP(nil), v.Addr[:]...), Port: v.Port}
+		if v.ZoneId != 0 {
+			if ifi, err := net.InterfaceByIndex(int(v.ZoneId)); err == nil {
+				addr.Zone = ifi.Name
+			}
+		}
+		return addr
+	default:
+		return nil
+	}
+}
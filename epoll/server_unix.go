@@ -0,0 +1,127 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package epoll
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+
+	"go.osspkg.com/errors"
+	"go.osspkg.com/logx"
+	"go.osspkg.com/xc"
+
+	"go.osspkg.com/network/listen"
+)
+
+type (
+	ConfigUnix struct {
+		// Addr is the socket file path or a Linux abstract name, e.g. @name. A stale
+		// file is removed before listening, the socket of a live process is an error.
+		Addr           string `yaml:"addr"`
+		CountEvents    uint   `yaml:"count_events,omitempty"`
+		WaitIntervalMS uint   `yaml:"wait_interval_ms,omitempty"`
+		MaxReadBuffer  uint   `yaml:"max_read_buffer,omitempty"`
+		Loops          uint   `yaml:"loops,omitempty"`
+		Balance        string `yaml:"balance,omitempty"`
+		Mode           string `yaml:"mode,omitempty"`
+		WriteHighWater uint   `yaml:"write_high_water,omitempty"`
+		MaxWriteBuffer uint   `yaml:"max_write_buffer,omitempty"`
+		Workers        uint   `yaml:"workers,omitempty"`
+		QueueSize      uint   `yaml:"queue_size,omitempty"`
+		QueuePolicy    string `yaml:"queue_policy,omitempty"`
+		Backend        string `yaml:"backend,omitempty"`
+		// Unix sets the mode and the owner of the socket file and keeps it on close,
+		// by default the file is removed on close, see listen.Unix.
+		Unix *listen.Unix `yaml:"unix,omitempty"`
+	}
+
+	// ServerUnix serves a unix stream socket by epoll loops, like ServerTCP.
+	ServerUnix struct {
+		stream
+		Handler func(ctx context.Context, w io.Writer, r io.Reader) error
+		Decoder Decoder
+		Metrics Metrics
+		// OnClose is called once when a connection is closed, see Option.
+		OnClose func(c TConnect)
+		Config  ConfigUnix
+	}
+)
+
+var _ Registry = (*ServerUnix)(nil)
+
+func (s *ServerUnix) init() error {
+	if s.Handler == nil {
+		return fmt.Errorf("epoll unix: handler is empty")
+	}
+	if s.Config.Addr == "" {
+		return fmt.Errorf("epoll unix: socket path is empty")
+	}
+	if s.Config.Unix != nil {
+		if err := s.Config.Unix.Validate(s.Config.Addr); err != nil {
+			return fmt.Errorf("epoll unix: %w", err)
+		}
+	}
+	if s.Config.CountEvents == 0 {
+		s.Config.CountEvents = 100
+	}
+	if s.Config.WaitIntervalMS == 0 {
+		s.Config.WaitIntervalMS = 500
+	}
+	return s.setup(Option{
+		Handler:        s.Handler,
+		CountEvents:    s.Config.CountEvents,
+		WaitIntervalMS: s.Config.WaitIntervalMS,
+		Decoder:        s.Decoder,
+		MaxReadBuffer:  s.Config.MaxReadBuffer,
+		Loops:          s.Config.Loops,
+		Balance:        s.Config.Balance,
+		Mode:           s.Config.Mode,
+		WriteHighWater: s.Config.WriteHighWater,
+		MaxWriteBuffer: s.Config.MaxWriteBuffer,
+		Workers:        s.Config.Workers,
+		QueueSize:      s.Config.QueueSize,
+		QueuePolicy:    s.Config.QueuePolicy,
+		Backend:        s.Config.Backend,
+		Metrics:        s.Metrics,
+		OnClose:        s.OnClose,
+	}, 1)
+}
+
+// listen checks that the socket file is stale and removes it on close, as the listen package does.
+func (s *ServerUnix) listen(ctx context.Context) error {
+	var opts []listen.Option
+	if s.Config.Unix != nil {
+		opts = s.Config.Unix.Options()
+	}
+	l, err := listen.New(ctx, "unix", s.Config.Addr, nil, opts...)
+	if err != nil {
+		return err
+	}
+	s.setListener(0, l.(net.Listener))
+	return nil
+}
+
+func (s *ServerUnix) ListenAndServe(ctx xc.Context) (err error) {
+	defer func() {
+		ctx.Close()
+		logx.Error("Epoll server stopped", "err", err, "ip", s.Config.Addr)
+	}()
+
+	if err = s.init(); err != nil {
+		return
+	}
+	defer func() {
+		err = errors.Wrap(err, s.closeListeners())
+	}()
+	if err = s.listen(ctx.Context()); err != nil {
+		err = errors.Wrap(err, s.release())
+		return
+	}
+	s.serve(ctx, s.Config.Addr)
+	return
+}
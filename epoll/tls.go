@@ -0,0 +1,119 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package epoll
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io"
+	"net"
+	"sync/atomic"
+	"syscall"
+
+	"go.osspkg.com/errors"
+	"golang.org/x/sys/unix"
+)
+
+// errWouldBlock is a temporary net.Error, so a TLS connection keeps
+// a partial record and can be read again on the next event.
+var errWouldBlock error = wouldBlockError{}
+
+type wouldBlockError struct{}
+
+func (wouldBlockError) Error() string   { return "epoll: socket would block" }
+func (wouldBlockError) Timeout() bool   { return true }
+func (wouldBlockError) Temporary() bool { return true }
+
+// sockConn is the transport under a TLS connection. It blocks during the handshake,
+// which is done before the connection is accepted by the loop, then it reads
+// the socket without waiting and writes through the connection write queue.
+type sockConn struct {
+	net.Conn
+	raw  syscall.RawConn
+	conn atomic.Pointer[connect]
+}
+
+func newSockConn(c net.Conn) net.Conn {
+	sc, ok := c.(syscall.Conn)
+	if !ok {
+		return c
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return c
+	}
+	return &sockConn{Conn: c, raw: raw}
+}
+
+func (v *sockConn) SyscallConn() (syscall.RawConn, error) {
+	return v.raw, nil
+}
+
+func (v *sockConn) Read(p []byte) (n int, err error) {
+	if v.conn.Load() == nil {
+		return v.Conn.Read(p)
+	}
+	e := v.raw.Read(func(fd uintptr) bool {
+		for {
+			n, err = unix.Read(int(fd), p)
+			if err != unix.EINTR {
+				return true
+			}
+		}
+	})
+	switch {
+	case e != nil:
+		return 0, e
+	case err == unix.EAGAIN:
+		return 0, errWouldBlock
+	case err != nil:
+		return 0, err
+	case n == 0 && len(p) > 0:
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+func (v *sockConn) Write(p []byte) (int, error) {
+	if c := v.conn.Load(); c != nil {
+		return c.queue(p)
+	}
+	return v.Conn.Write(p)
+}
+
+// tlsSock returns the transport of a TLS connection created by ServerTCP.
+func tlsSock(c net.Conn) (*tls.Conn, *sockConn, bool) {
+	tc, ok := c.(*tls.Conn)
+	if !ok {
+		return nil, nil, false
+	}
+	sc, ok := tc.NetConn().(*sockConn)
+	return tc, sc, ok
+}
+
+// drainTLS reads decrypted records until the socket has no more data,
+// records buffered by the TLS connection do not raise an event.
+func (v *connect) drainTLS(w *bytes.Buffer) (n int, err error) {
+	for {
+		b := w.AvailableBuffer()
+		if cap(b) < 1024 {
+			w.Grow(4096)
+			b = w.AvailableBuffer()
+		}
+		b = b[:cap(b)]
+		m, e := v.tls.Read(b)
+		if m > 0 {
+			w.Write(b[:m]) // nolint: errcheck
+			n += m
+		}
+		if e != nil {
+			if errors.Is(e, errWouldBlock) {
+				return n, nil
+			}
+			return n, e
+		}
+	}
+}
@@ -0,0 +1,419 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package epoll
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"go.osspkg.com/errors"
+	"go.osspkg.com/logx"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	uringOpPollAdd    = 6
+	uringOpPollRemove = 7
+
+	uringPollAddMulti = 1 << 0
+	uringCQEFMore     = 1 << 1
+
+	uringEnterGetEvents = 1 << 0
+	uringEnterExtArg    = 1 << 3
+
+	uringFeatSingleMmap = 1 << 0
+	uringFeatExtArg     = 1 << 8
+	// uringFeatRsrcTags came in Linux 5.13 together with the multishot poll.
+	uringFeatRsrcTags = 1 << 10
+
+	uringOffSQRing = 0
+	uringOffSQEs   = 0x10000000
+
+	// uringRemove marks the user data of the remove requests, it is set on the user data
+	// of the removed poll, their completions are skipped.
+	uringRemove = 1 << 63
+	uringGenMax = 1<<31 - 1
+)
+
+type (
+	uringParams struct {
+		sqEntries    uint32
+		cqEntries    uint32
+		flags        uint32
+		sqThreadCPU  uint32
+		sqThreadIdle uint32
+		features     uint32
+		wqFd         uint32
+		resv         [3]uint32
+		sqOff        uringSQOffsets
+		cqOff        uringCQOffsets
+	}
+	uringSQOffsets struct {
+		head, tail, ringMask, ringEntries, flags, dropped, array, resv1 uint32
+		userAddr                                                        uint64
+	}
+	uringCQOffsets struct {
+		head, tail, ringMask, ringEntries, overflow, cqes, flags, resv1 uint32
+		userAddr                                                        uint64
+	}
+	uringSQE struct {
+		opcode      uint8
+		flags       uint8
+		ioprio      uint16
+		fd          int32
+		off         uint64
+		addr        uint64
+		len         uint32
+		opFlags     uint32
+		userData    uint64
+		bufIndex    uint16
+		personality uint16
+		spliceFdIn  int32
+		addr3       uint64
+		pad         uint64
+	}
+	uringCQE struct {
+		userData uint64
+		res      int32
+		flags    uint32
+	}
+	uringGetEventsArg struct {
+		sigmask   uint64
+		sigmaskSz uint32
+		pad       uint32
+		ts        uint64
+	}
+
+	// uringPoll is the poll request of a descriptor, gen tells the current completion from a stale one.
+	uringPoll struct {
+		gen    uint32
+		events uint32
+		armed  bool
+	}
+
+	// _uring waits for readiness with io_uring poll requests instead of epoll_wait,
+	// it is the experimental poll-only backend. The socket is still read and written
+	// by the connection, so TLS and decoders work the same way.
+	_uring struct {
+		fd   int
+		mode string
+		// mux guards the submission queue and the polls, the completion queue
+		// is read only by the loop.
+		mux     sync.Mutex
+		polls   map[int32]*uringPoll
+		gen     uint32
+		pending uint32
+
+		sqHead, sqTail *uint32
+		sqMask         uint32
+		sqEntries      uint32
+		sqArray        []uint32
+		sqes           []uringSQE
+
+		cqHead, cqTail *uint32
+		cqMask         uint32
+		cqes           []uringCQE
+
+		ring, sqeMem []byte
+		// pfds is the readiness check of the level events, it is used only by the loop.
+		pfds []unix.PollFd
+		// ts and arg are passed to the kernel by address, they live on the heap with the ring.
+		ts  unix.Timespec
+		arg uringGetEventsArg
+	}
+)
+
+func newUring(c Option) (*_uring, error) {
+	entries := uint32(64)
+	for entries < uint32(c.CountEvents)*2 && entries < 4096 {
+		entries <<= 1
+	}
+	var p uringParams
+	fd, _, errno := unix.Syscall(unix.SYS_IO_URING_SETUP, uintptr(entries), uintptr(unsafe.Pointer(&p)), 0)
+	if errno != 0 {
+		return nil, fmt.Errorf("io_uring setup: %w", errno)
+	}
+	v := &_uring{
+		fd:    int(fd),
+		mode:  c.Mode,
+		polls: make(map[int32]*uringPoll, c.CountEvents),
+	}
+	need := uint32(uringFeatSingleMmap | uringFeatExtArg | uringFeatRsrcTags)
+	if p.features&need != need {
+		return nil, errors.Wrap(fmt.Errorf("io_uring: linux 5.13 or newer is required"), unix.Close(v.fd))
+	}
+	if err := v.mmap(&p); err != nil {
+		return nil, errors.Wrap(err, v.close())
+	}
+	return v, nil
+}
+
+func (v *_uring) mmap(p *uringParams) (err error) {
+	size := p.sqOff.array + p.sqEntries*4
+	if cq := p.cqOff.cqes + p.cqEntries*uint32(unsafe.Sizeof(uringCQE{})); cq > size {
+		size = cq
+	}
+	prot, flags := unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE
+	if v.ring, err = unix.Mmap(v.fd, uringOffSQRing, int(size), prot, flags); err != nil {
+		return fmt.Errorf("io_uring mmap ring: %w", err)
+	}
+	sqeSize := int(p.sqEntries) * int(unsafe.Sizeof(uringSQE{}))
+	if v.sqeMem, err = unix.Mmap(v.fd, uringOffSQEs, sqeSize, prot, flags); err != nil {
+		return fmt.Errorf("io_uring mmap sqes: %w", err)
+	}
+
+	base := unsafe.Pointer(&v.ring[0])
+	v.sqHead = (*uint32)(unsafe.Add(base, p.sqOff.head))
+	v.sqTail = (*uint32)(unsafe.Add(base, p.sqOff.tail))
+	v.sqMask = *(*uint32)(unsafe.Add(base, p.sqOff.ringMask))
+	v.sqEntries = p.sqEntries
+	v.sqArray = unsafe.Slice((*uint32)(unsafe.Add(base, p.sqOff.array)), p.sqEntries)
+	v.sqes = unsafe.Slice((*uringSQE)(unsafe.Pointer(&v.sqeMem[0])), p.sqEntries)
+	v.cqHead = (*uint32)(unsafe.Add(base, p.cqOff.head))
+	v.cqTail = (*uint32)(unsafe.Add(base, p.cqOff.tail))
+	v.cqMask = *(*uint32)(unsafe.Add(base, p.cqOff.ringMask))
+	v.cqes = unsafe.Slice((*uringCQE)(unsafe.Add(base, p.cqOff.cqes)), p.cqEntries)
+	return nil
+}
+
+func (v *_uring) add(fd int32, events uint32) error {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+
+	p := &uringPoll{events: events}
+	v.polls[fd] = p
+	if err := v.pollAdd(fd, p); err != nil {
+		return err
+	}
+	return v.submit()
+}
+
+func (v *_uring) mod(fd int32, events uint32) error {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+
+	p, ok := v.polls[fd]
+	if !ok {
+		return unix.ENOENT
+	}
+	if err := v.pollRemove(fd, p); err != nil {
+		return err
+	}
+	p.events = events
+	if err := v.pollAdd(fd, p); err != nil {
+		return err
+	}
+	return v.submit()
+}
+
+func (v *_uring) del(fd int32) error {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+
+	p, ok := v.polls[fd]
+	if !ok {
+		return unix.ENOENT
+	}
+	delete(v.polls, fd)
+	if err := v.pollRemove(fd, p); err != nil {
+		return err
+	}
+	return v.submit()
+}
+
+func (v *_uring) wait(events []unix.EpollEvent, msec int) (int, error) {
+	if atomic.LoadUint32(v.cqTail) == atomic.LoadUint32(v.cqHead) {
+		v.ts = unix.NsecToTimespec(int64(msec) * 1e6)
+		v.arg = uringGetEventsArg{ts: uint64(uintptr(unsafe.Pointer(&v.ts)))}
+		_, err := v.enter(0, 1, uringEnterGetEvents|uringEnterExtArg, unsafe.Pointer(&v.arg), unsafe.Sizeof(v.arg))
+		if err != nil && !errors.Is(err, unix.ETIME) && !errors.Is(err, unix.EINTR) {
+			return 0, err
+		}
+	}
+
+	v.mux.Lock()
+	defer v.mux.Unlock()
+
+	n, head := 0, atomic.LoadUint32(v.cqHead)
+	for tail := atomic.LoadUint32(v.cqTail); head != tail && n < len(events); head++ {
+		if ev, ok := v.complete(v.cqes[head&v.cqMask]); ok {
+			events[n] = ev
+			n++
+		}
+	}
+	atomic.StoreUint32(v.cqHead, head)
+	if v.mode != ModeEdge && v.mode != ModeOneShot {
+		n = v.recheck(events[:n])
+	}
+	// the rearms of the level mode go in one submission
+	return n, v.submit()
+}
+
+// recheck keeps the level events whose sockets are still ready. A completion
+// waits in the queue while a busy handler reads the socket, so it is checked
+// again on return as epoll_wait does, the level handler must not read an empty socket.
+func (v *_uring) recheck(events []unix.EpollEvent) int {
+	if len(events) == 0 {
+		return 0
+	}
+	v.pfds = v.pfds[:0]
+	for _, ev := range events {
+		var mask uint32
+		if p, ok := v.polls[ev.Fd]; ok {
+			mask = p.events
+		}
+		v.pfds = append(v.pfds, unix.PollFd{Fd: ev.Fd, Events: int16(mask & 0xffff)})
+	}
+	if _, err := unix.Poll(v.pfds, 0); err != nil {
+		return len(events)
+	}
+	n := 0
+	for i, pfd := range v.pfds {
+		if pfd.Revents == 0 && events[i].Events&unix.POLLERR == 0 {
+			continue
+		}
+		if pfd.Revents != 0 {
+			events[i].Events = uint32(uint16(pfd.Revents))
+		}
+		events[n] = events[i]
+		n++
+	}
+	return n
+}
+
+// complete turns a poll completion into an epoll event, stale completions are skipped.
+func (v *_uring) complete(cqe uringCQE) (unix.EpollEvent, bool) {
+	if cqe.userData&uringRemove != 0 {
+		// the poll is woken up at the moment and is armed again if the socket is not ready,
+		// it holds the socket open after close until it is removed
+		if cqe.res == -int32(unix.EALREADY) {
+			if err := v.push(removeSQE(cqe.userData &^ uringRemove)); err != nil {
+				logx.Error("Epoll io_uring remove poll", "err", err)
+			}
+		}
+		return unix.EpollEvent{}, false
+	}
+	fd, gen := int32(uint32(cqe.userData)), uint32(cqe.userData>>32)
+	p, ok := v.polls[fd]
+	if !ok || p.gen != gen {
+		return unix.EpollEvent{}, false
+	}
+	if cqe.res < 0 {
+		p.armed = false
+		if cqe.res == -int32(unix.ECANCELED) {
+			return unix.EpollEvent{}, false
+		}
+		return unix.EpollEvent{Events: unix.POLLERR, Fd: fd}, true
+	}
+	switch {
+	case v.mode == ModeOneShot:
+		p.armed = false
+	case v.mode == ModeEdge && cqe.flags&uringCQEFMore != 0:
+	default:
+		// a single shot poll is rearmed to keep the level semantics,
+		// it completes at once while the socket is still readable
+		p.armed = false
+		if err := v.pollAdd(fd, p); err != nil {
+			return unix.EpollEvent{Events: unix.POLLERR, Fd: fd}, true
+		}
+	}
+	return unix.EpollEvent{Events: uint32(cqe.res), Fd: fd}, true
+}
+
+func (v *_uring) pollAdd(fd int32, p *uringPoll) error {
+	v.gen = v.gen%uringGenMax + 1
+	p.gen, p.armed = v.gen, true
+	sqe := uringSQE{
+		opcode:   uringOpPollAdd,
+		fd:       fd,
+		opFlags:  p.events &^ (unix.EPOLLET | unix.EPOLLONESHOT),
+		userData: uint64(p.gen)<<32 | uint64(uint32(fd)),
+	}
+	if v.mode == ModeEdge {
+		sqe.len = uringPollAddMulti
+	}
+	return v.push(sqe)
+}
+
+func (v *_uring) pollRemove(fd int32, p *uringPoll) error {
+	if !p.armed {
+		return nil
+	}
+	p.armed = false
+	return v.push(removeSQE(uint64(p.gen)<<32 | uint64(uint32(fd))))
+}
+
+func removeSQE(target uint64) uringSQE {
+	return uringSQE{
+		opcode:   uringOpPollRemove,
+		fd:       -1,
+		addr:     target,
+		userData: uringRemove | target,
+	}
+}
+
+// push puts the request to the submission queue, a full queue is submitted first.
+func (v *_uring) push(sqe uringSQE) error {
+	tail := atomic.LoadUint32(v.sqTail)
+	if tail-atomic.LoadUint32(v.sqHead) >= v.sqEntries {
+		if err := v.submit(); err != nil {
+			return err
+		}
+		if tail-atomic.LoadUint32(v.sqHead) >= v.sqEntries {
+			return fmt.Errorf("io_uring: submission queue is full")
+		}
+	}
+	idx := tail & v.sqMask
+	v.sqes[idx] = sqe
+	v.sqArray[idx] = idx
+	atomic.StoreUint32(v.sqTail, tail+1)
+	v.pending++
+	return nil
+}
+
+func (v *_uring) submit() error {
+	for v.pending > 0 {
+		n, err := v.enter(v.pending, 0, 0, nil, 0)
+		switch {
+		case errors.Is(err, unix.EINTR):
+			continue
+		case err != nil:
+			return fmt.Errorf("io_uring submit: %w", err)
+		case n == 0:
+			return fmt.Errorf("io_uring submit: no request is taken")
+		}
+		v.pending -= uint32(n)
+	}
+	return nil
+}
+
+func (v *_uring) enter(submit, complete, flags uint32, arg unsafe.Pointer, size uintptr) (int, error) {
+	n, _, errno := unix.Syscall6(unix.SYS_IO_URING_ENTER, uintptr(v.fd),
+		uintptr(submit), uintptr(complete), uintptr(flags), uintptr(arg), size)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(n), nil
+}
+
+func (v *_uring) close() (err error) {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+
+	if v.sqeMem != nil {
+		err = errors.Wrap(err, unix.Munmap(v.sqeMem))
+	}
+	if v.ring != nil {
+		err = errors.Wrap(err, unix.Munmap(v.ring))
+	}
+	return errors.Wrap(err, unix.Close(v.fd))
+}
+
+func (v *_uring) backend() string {
+	return BackendIOUringPoll
+}
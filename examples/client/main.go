@@ -8,7 +8,6 @@ package main
 import (
 	"context"
 	"fmt"
-	"io"
 	"os"
 	"sync/atomic"
 	"time"
@@ -17,6 +16,7 @@ import (
 	"go.osspkg.com/syncing"
 
 	"go.osspkg.com/network/client"
+	"go.osspkg.com/network/framing"
 )
 
 func main() {
@@ -30,6 +30,8 @@ func main() {
 		config.Certificate = &client.Certificate{InsecureSkipVerify: true}
 	}
 
+	codec := framing.NewUint32(1024)
+
 	cli, err := client.New(config)
 	if err != nil {
 		panic(err)
@@ -47,17 +49,11 @@ func main() {
 			i := i
 			wg.Background(func() {
 				buff := data.NewBuffer(1024)
-				buff.WriteString(fmt.Sprintf("<- %d ->", i))
-				err := cli.Call(context.TODO(), func(ctx context.Context, w io.Writer, r io.Reader) error {
-					if _, err := buff.WriteTo(w); err != nil {
-						return err
-					}
-					buff.Reset()
-					if _, err := buff.ReadFrom(r); err != nil {
-						return err
-					}
-					return nil
-				})
+				req := []byte(fmt.Sprintf("<- %d ->", i))
+				err := cli.Call(context.TODO(), framing.Exchange(codec, req, func(resp []byte) error {
+					_, err := buff.Write(resp)
+					return err
+				}))
 				if err != nil {
 					fmt.Println(i, "E", err)
 					atomic.AddInt64(&fail, 1)
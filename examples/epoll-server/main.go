@@ -7,23 +7,21 @@ package main
 
 import (
 	"context"
-	"io"
 
 	"go.osspkg.com/xc"
 
 	"go.osspkg.com/network/epoll"
+	"go.osspkg.com/network/framing"
 )
 
 func main() {
+	codec := framing.NewDelimiter([]byte("\n"), 1024)
+
 	serv := &epoll.ServerTCP{
-		Handler: func(_ context.Context, w io.Writer, r io.Reader) error {
-			b, err := io.ReadAll(r)
-			if err != nil {
-				return err
-			}
-			_, err = w.Write(append([]byte(">> "), b...))
-			return err
-		},
+		Handler: framing.FrameHandler(codec, func(_ context.Context, msg []byte) ([]byte, error) {
+			return append([]byte(">> "), msg...), nil
+		}),
+		Decoder: codec,
 		Config: epoll.ConfigTCP{
 			Addr:           "127.0.0.1:8888",
 			CountEvents:    100,
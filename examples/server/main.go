@@ -8,13 +8,12 @@ package main
 import (
 	"context"
 	"fmt"
-	"io"
 	"net"
 	"os"
 
-	"go.osspkg.com/ioutils/data"
 	"go.osspkg.com/logx"
 
+	"go.osspkg.com/network/framing"
 	"go.osspkg.com/network/listen"
 	"go.osspkg.com/network/server"
 )
@@ -22,10 +21,10 @@ import (
 func main() {
 	logx.SetLevel(logx.LevelDebug)
 
-	config := server.Config{
+	config := server.Config{Listener: server.Listener{
 		Address: os.Getenv("ADDRESS"),
 		Network: os.Getenv("NETWORK"),
-	}
+	}}
 
 	if config.Network == "quic" {
 		config.SSL = &server.SSL{
@@ -37,13 +36,11 @@ func main() {
 
 	srv := server.New(config)
 
-	srv.HandleFunc(func(ctx context.Context, w io.Writer, r io.Reader, addr net.Addr) {
-		buff := data.NewBuffer(1024)
-		_, err := buff.ReadFrom(r)
-		fmt.Println("[------", addr.String(), "------]", err, buff.String())
-		buff.Seek(0, 0)
-		buff.WriteTo(w)
-	})
+	srv.HandleFunc(framing.ServerHandler(framing.NewUint32(1024),
+		func(_ context.Context, msg []byte, addr net.Addr) ([]byte, error) {
+			fmt.Println("[------", addr.String(), "------]", string(msg))
+			return msg, nil
+		}))
 
 	if err := srv.ListenAndServe(context.TODO()); err != nil {
 		panic(err)
@@ -6,12 +6,61 @@
 package fd
 
 import (
+	"fmt"
 	"net"
-	"reflect"
+	"syscall"
+
+	"go.osspkg.com/errors"
 )
 
-func ByConnect(c net.Conn) int64 {
-	fd := reflect.Indirect(reflect.ValueOf(c)).FieldByName("fd")
-	pfd := reflect.Indirect(fd).FieldByName("pfd")
-	return pfd.FieldByName("Sysfd").Int()
+var ErrNoFD = errors.New("connection has no file descriptor")
+
+// maxUnwrap guards against wrappers returning each other.
+const maxUnwrap = 16
+
+// Unwrap returns the innermost connection of wrappers such as *tls.Conn,
+// it follows NetConn() and Unwrap() until a syscall.Conn is found.
+func Unwrap(c net.Conn) net.Conn {
+	for i := 0; i < maxUnwrap && c != nil; i++ {
+		if _, ok := c.(syscall.Conn); ok {
+			return c
+		}
+		switch v := c.(type) {
+		case interface{ NetConn() net.Conn }:
+			c = v.NetConn()
+		case interface{ Unwrap() net.Conn }:
+			c = v.Unwrap()
+		default:
+			return c
+		}
+	}
+	return c
+}
+
+// SyscallConn returns the raw connection of c or of the connection wrapped by it.
+func SyscallConn(c net.Conn) (syscall.RawConn, error) {
+	sc, ok := Unwrap(c).(syscall.Conn)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrNoFD, c)
+	}
+	return sc.SyscallConn()
+}
+
+// ByConnect returns the socket file descriptor of the connection. The descriptor
+// is owned by the connection and is valid until it is closed.
+func ByConnect(c net.Conn) (int, error) {
+	raw, err := SyscallConn(c)
+	if err != nil {
+		return 0, err
+	}
+	fd := -1
+	if err = raw.Control(func(v uintptr) {
+		fd = int(v)
+	}); err != nil {
+		return 0, err
+	}
+	if fd < 0 {
+		return 0, fmt.Errorf("%w: %T", ErrNoFD, c)
+	}
+	return fd, nil
 }
@@ -0,0 +1,50 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package fd_test
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"go.osspkg.com/casecheck"
+
+	"go.osspkg.com/network/fd"
+)
+
+func TestUnit_ByConnect(t *testing.T) {
+	for _, network := range []string{"tcp", "unix"} {
+		t.Run(network, func(t *testing.T) {
+			addr := "127.0.0.1:0"
+			if network == "unix" {
+				addr = filepath.Join(t.TempDir(), "fd.sock")
+			}
+			l, err := net.Listen(network, addr)
+			casecheck.NoError(t, err)
+			defer l.Close() // nolint: errcheck
+
+			conn, err := net.Dial(network, l.Addr().String())
+			casecheck.NoError(t, err)
+			defer conn.Close() // nolint: errcheck
+
+			v, err := fd.ByConnect(conn)
+			casecheck.NoError(t, err)
+			casecheck.True(t, v > 0)
+
+			tv, err := fd.ByConnect(tls.Client(conn, &tls.Config{}))
+			casecheck.NoError(t, err)
+			casecheck.Equal(t, v, tv)
+		})
+	}
+
+	a, b := net.Pipe()
+	defer a.Close() // nolint: errcheck
+	defer b.Close() // nolint: errcheck
+	_, err := fd.ByConnect(a)
+	casecheck.True(t, errors.Is(err, fd.ErrNoFD), err)
+}
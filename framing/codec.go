@@ -0,0 +1,85 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package framing
+
+import (
+	"bufio"
+	"io"
+
+	"go.osspkg.com/errors"
+)
+
+var (
+	ErrFrameTooLarge = errors.New("frame exceeds max size")
+	ErrInvalidFrame  = errors.New("invalid frame")
+)
+
+// Codec splits a byte stream into frames and encodes frames back.
+type Codec interface {
+	// Encode writes p as one frame.
+	Encode(w io.Writer, p []byte) error
+	// Decode is compatible with bufio.SplitFunc: it returns the number of bytes
+	// consumed and the frame payload, or zero advance when more data is needed.
+	Decode(data []byte, atEOF bool) (advance int, frame []byte, err error)
+	// BufferSize is the largest encoded frame, payload with the codec overhead.
+	BufferSize() int
+}
+
+type Reader struct {
+	scan *bufio.Scanner
+}
+
+func NewReader(r io.Reader, c Codec) *Reader {
+	scan := bufio.NewScanner(r)
+	scan.Buffer(make([]byte, 0, min(4096, c.BufferSize())), c.BufferSize())
+	scan.Split(c.Decode)
+	return &Reader{scan: scan}
+}
+
+// ReadFrame returns the next frame, the slice is valid until the next call.
+// io.EOF is returned when the stream ends on a frame boundary.
+func (v *Reader) ReadFrame() ([]byte, error) {
+	if v.scan.Scan() {
+		return v.scan.Bytes(), nil
+	}
+	err := v.scan.Err()
+	switch {
+	case err == nil:
+		return nil, io.EOF
+	case errors.Is(err, bufio.ErrTooLong):
+		return nil, ErrFrameTooLarge
+	default:
+		return nil, err
+	}
+}
+
+type Writer struct {
+	w     io.Writer
+	codec Codec
+}
+
+func NewWriter(w io.Writer, c Codec) *Writer {
+	return &Writer{w: w, codec: c}
+}
+
+func (v *Writer) WriteFrame(p []byte) error {
+	return v.codec.Encode(v.w, p)
+}
+
+// writeBuffers sends the frame with a single Write, so a datagram
+// connection never splits the header and payload into different packets.
+func writeBuffers(w io.Writer, b ...[]byte) error {
+	size := 0
+	for _, v := range b {
+		size += len(v)
+	}
+	frame := make([]byte, 0, size)
+	for _, v := range b {
+		frame = append(frame, v...)
+	}
+	_, err := w.Write(frame)
+	return err
+}
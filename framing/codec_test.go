@@ -0,0 +1,91 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package framing_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"go.osspkg.com/casecheck"
+
+	"go.osspkg.com/network/framing"
+)
+
+func TestUnit_Codecs(t *testing.T) {
+	codecs := map[string]framing.Codec{
+		"uint16":    framing.NewUint16(1024),
+		"uint32":    framing.NewUint32(1024),
+		"varint":    framing.NewVarint(1024),
+		"delimiter": framing.NewDelimiter([]byte("\r\n"), 1024),
+		"netstring": framing.NewNetstring(1024),
+	}
+	frames := [][]byte{[]byte("hello"), {}, []byte(strings.Repeat("x", 1024)), []byte("world")}
+
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			w := framing.NewWriter(buf, codec)
+			for _, f := range frames {
+				casecheck.NoError(t, w.WriteFrame(f))
+			}
+			casecheck.True(t, errors.Is(w.WriteFrame(make([]byte, 1025)), framing.ErrFrameTooLarge))
+
+			r := framing.NewReader(iotest.OneByteReader(bytes.NewReader(buf.Bytes())), codec)
+			for _, f := range frames {
+				got, err := r.ReadFrame()
+				casecheck.NoError(t, err)
+				casecheck.Equal(t, string(f), string(got))
+			}
+			_, err := r.ReadFrame()
+			casecheck.True(t, errors.Is(err, io.EOF), err)
+
+			r = framing.NewReader(bytes.NewReader(buf.Bytes()[:buf.Len()-1]), codec)
+			for range frames[:len(frames)-1] {
+				_, err = r.ReadFrame()
+				casecheck.NoError(t, err)
+			}
+			_, err = r.ReadFrame()
+			casecheck.True(t, errors.Is(err, io.ErrUnexpectedEOF), err)
+		})
+	}
+}
+
+func TestUnit_DecodeErrors(t *testing.T) {
+	_, _, err := framing.NewUint16(10).Decode([]byte{0, 11}, false)
+	casecheck.True(t, errors.Is(err, framing.ErrFrameTooLarge), err)
+
+	_, _, err = framing.NewDelimiter([]byte("\n"), 4).Decode([]byte("123456"), false)
+	casecheck.True(t, errors.Is(err, framing.ErrFrameTooLarge), err)
+
+	_, _, err = framing.NewNetstring(100).Decode([]byte("3:abc;"), false)
+	casecheck.True(t, errors.Is(err, framing.ErrInvalidFrame), err)
+
+	_, _, err = framing.NewNetstring(100).Decode([]byte("1000:"), false)
+	casecheck.True(t, errors.Is(err, framing.ErrInvalidFrame), err)
+}
+
+func TestUnit_FrameHandler(t *testing.T) {
+	codec := framing.NewVarint(0)
+	out := bytes.NewBuffer(nil)
+	h := framing.FrameHandler(codec, func(_ context.Context, msg []byte) ([]byte, error) {
+		return append([]byte(">> "), msg...), nil
+	})
+	for _, msg := range []string{"a", "b"} {
+		casecheck.NoError(t, h(context.TODO(), out, strings.NewReader(msg)))
+	}
+
+	r := framing.NewReader(out, codec)
+	for _, want := range []string{">> a", ">> b"} {
+		got, err := r.ReadFrame()
+		casecheck.NoError(t, err)
+		casecheck.Equal(t, want, string(got))
+	}
+}
@@ -0,0 +1,57 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package framing
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+)
+
+type delimiterCodec struct {
+	delim []byte
+	max   int
+}
+
+// NewDelimiter ends every frame with delim, e.g. "\n" or "\r\n".
+// The payload itself must not contain the delimiter.
+func NewDelimiter(delim []byte, maxSize int) Codec {
+	if len(delim) == 0 {
+		delim = []byte{'\n'}
+	}
+	return &delimiterCodec{
+		delim: append([]byte{}, delim...),
+		max:   limit(maxSize, math.MaxInt32),
+	}
+}
+
+func (v *delimiterCodec) BufferSize() int {
+	return v.max + len(v.delim)
+}
+
+func (v *delimiterCodec) Encode(w io.Writer, p []byte) error {
+	if len(p) > v.max {
+		return fmt.Errorf("%w: %d > %d", ErrFrameTooLarge, len(p), v.max)
+	}
+	if bytes.Contains(p, v.delim) {
+		return fmt.Errorf("%w: payload contains delimiter", ErrInvalidFrame)
+	}
+	return writeBuffers(w, p, v.delim)
+}
+
+func (v *delimiterCodec) Decode(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.Index(data, v.delim); i >= 0 {
+		if i > v.max {
+			return 0, nil, fmt.Errorf("%w: %d > %d", ErrFrameTooLarge, i, v.max)
+		}
+		return i + len(v.delim), data[:i], nil
+	}
+	if len(data) > v.BufferSize() {
+		return 0, nil, fmt.Errorf("%w: no delimiter in %d bytes", ErrFrameTooLarge, len(data))
+	}
+	return incomplete(data, atEOF)
+}
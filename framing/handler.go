@@ -0,0 +1,94 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package framing
+
+import (
+	"context"
+	"io"
+	"net"
+
+	"go.osspkg.com/errors"
+
+	"go.osspkg.com/network/internal"
+)
+
+// ServerHandler adapts a message handler to server.HandleFunc. Every decoded
+// frame is passed to fn, a non-nil response is written back as one frame.
+// The connection is served until the peer closes it or fn returns an error.
+func ServerHandler(
+	c Codec, fn func(ctx context.Context, msg []byte, addr net.Addr) ([]byte, error),
+) func(ctx context.Context, w io.Writer, r io.Reader, addr net.Addr) {
+	return func(ctx context.Context, w io.Writer, r io.Reader, addr net.Addr) {
+		reader, writer := NewReader(r, c), NewWriter(w, c)
+		for {
+			msg, err := reader.ReadFrame()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					internal.Log("Framing: read frame", err, addr)
+				}
+				return
+			}
+			resp, err := fn(ctx, msg, addr)
+			if err != nil {
+				internal.Log("Framing: handler", err, addr)
+				return
+			}
+			if resp == nil {
+				continue
+			}
+			if err = writer.WriteFrame(resp); err != nil {
+				internal.Log("Framing: write frame", err, addr)
+				return
+			}
+		}
+	}
+}
+
+// ClientHandler adapts a frame-based exchange to client.Call.
+func ClientHandler(
+	c Codec, fn func(ctx context.Context, w *Writer, r *Reader) error,
+) func(ctx context.Context, w io.Writer, r io.Reader) error {
+	return func(ctx context.Context, w io.Writer, r io.Reader) error {
+		return fn(ctx, NewWriter(w, c), NewReader(r, c))
+	}
+}
+
+// Exchange is a client.Call handler that sends req as one frame and
+// passes the single response frame to fn.
+func Exchange(c Codec, req []byte, fn func(resp []byte) error) func(ctx context.Context, w io.Writer, r io.Reader) error {
+	return ClientHandler(c, func(_ context.Context, w *Writer, r *Reader) error {
+		if err := w.WriteFrame(req); err != nil {
+			return err
+		}
+		resp, err := r.ReadFrame()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		return fn(resp)
+	})
+}
+
+// FrameHandler adapts a message handler to epoll.Option.Handler when the epoll
+// server decodes frames itself (epoll.Option.Decoder set to the same codec):
+// r holds exactly one frame payload and the response is encoded as a frame.
+func FrameHandler(
+	c Codec, fn func(ctx context.Context, msg []byte) ([]byte, error),
+) func(ctx context.Context, w io.Writer, r io.Reader) error {
+	return func(ctx context.Context, w io.Writer, r io.Reader) error {
+		msg, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		resp, err := fn(ctx, msg)
+		if err != nil || resp == nil {
+			return err
+		}
+		return c.Encode(w, resp)
+	}
+}
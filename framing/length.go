@@ -0,0 +1,105 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package framing
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+type lengthCodec struct {
+	size int
+	max  int
+}
+
+// NewUint16 prefixes every frame with its length as 2 bytes big-endian.
+func NewUint16(maxSize int) Codec {
+	return &lengthCodec{size: 2, max: limit(maxSize, math.MaxUint16)}
+}
+
+// NewUint32 prefixes every frame with its length as 4 bytes big-endian.
+func NewUint32(maxSize int) Codec {
+	return &lengthCodec{size: 4, max: limit(maxSize, math.MaxInt32)}
+}
+
+// NewVarint prefixes every frame with its length as unsigned varint.
+func NewVarint(maxSize int) Codec {
+	return &lengthCodec{size: 0, max: limit(maxSize, math.MaxInt32)}
+}
+
+func limit(v, upper int) int {
+	if v <= 0 || v > upper {
+		return upper
+	}
+	return v
+}
+
+func (v *lengthCodec) BufferSize() int {
+	if v.size == 0 {
+		return v.max + binary.MaxVarintLen64
+	}
+	return v.max + v.size
+}
+
+func (v *lengthCodec) Encode(w io.Writer, p []byte) error {
+	if len(p) > v.max {
+		return fmt.Errorf("%w: %d > %d", ErrFrameTooLarge, len(p), v.max)
+	}
+
+	var hdr [binary.MaxVarintLen64]byte
+	n := v.size
+	switch v.size {
+	case 2:
+		binary.BigEndian.PutUint16(hdr[:], uint16(len(p)))
+	case 4:
+		binary.BigEndian.PutUint32(hdr[:], uint32(len(p)))
+	default:
+		n = binary.PutUvarint(hdr[:], uint64(len(p)))
+	}
+	return writeBuffers(w, hdr[:n], p)
+}
+
+func (v *lengthCodec) Decode(data []byte, atEOF bool) (int, []byte, error) {
+	var (
+		length uint64
+		n      = v.size
+	)
+	switch {
+	case v.size == 0:
+		length, n = binary.Uvarint(data)
+		if n < 0 {
+			return 0, nil, fmt.Errorf("%w: varint overflow", ErrInvalidFrame)
+		}
+	case len(data) < v.size:
+		n = 0
+	case v.size == 2:
+		length = uint64(binary.BigEndian.Uint16(data))
+	default:
+		length = uint64(binary.BigEndian.Uint32(data))
+	}
+
+	if n == 0 {
+		return incomplete(data, atEOF)
+	}
+	if length > uint64(v.max) {
+		return 0, nil, fmt.Errorf("%w: %d > %d", ErrFrameTooLarge, length, v.max)
+	}
+
+	end := n + int(length)
+	if len(data) < end {
+		return incomplete(data, atEOF)
+	}
+	return end, data[n:end], nil
+}
+
+func incomplete(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) > 0 {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,70 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package framing
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+type netstringCodec struct {
+	max    int
+	digits int
+}
+
+// NewNetstring encodes frames as netstrings: `<length>:<payload>,`.
+func NewNetstring(maxSize int) Codec {
+	maxSize = limit(maxSize, math.MaxInt32)
+	return &netstringCodec{
+		max:    maxSize,
+		digits: len(strconv.Itoa(maxSize)),
+	}
+}
+
+func (v *netstringCodec) BufferSize() int {
+	return v.max + v.digits + 2
+}
+
+func (v *netstringCodec) Encode(w io.Writer, p []byte) error {
+	if len(p) > v.max {
+		return fmt.Errorf("%w: %d > %d", ErrFrameTooLarge, len(p), v.max)
+	}
+	hdr := strconv.AppendInt(make([]byte, 0, v.digits+1), int64(len(p)), 10)
+	return writeBuffers(w, append(hdr, ':'), p, []byte{','})
+}
+
+func (v *netstringCodec) Decode(data []byte, atEOF bool) (int, []byte, error) {
+	i := bytes.IndexByte(data, ':')
+	if i < 0 {
+		if len(data) > v.digits {
+			return 0, nil, fmt.Errorf("%w: length prefix is too long", ErrInvalidFrame)
+		}
+		return incomplete(data, atEOF)
+	}
+	if i == 0 || i > v.digits || (i > 1 && data[0] == '0') {
+		return 0, nil, fmt.Errorf("%w: bad length prefix", ErrInvalidFrame)
+	}
+
+	length, err := strconv.Atoi(string(data[:i]))
+	if err != nil || length < 0 {
+		return 0, nil, fmt.Errorf("%w: bad length prefix", ErrInvalidFrame)
+	}
+	if length > v.max {
+		return 0, nil, fmt.Errorf("%w: %d > %d", ErrFrameTooLarge, length, v.max)
+	}
+
+	end := i + 1 + length
+	if len(data) <= end {
+		return incomplete(data, atEOF)
+	}
+	if data[end] != ',' {
+		return 0, nil, fmt.Errorf("%w: missing trailing comma", ErrInvalidFrame)
+	}
+	return end + 1, data[i+1 : end], nil
+}
@@ -4,6 +4,7 @@ go 1.23.6
 
 require (
 	github.com/quic-go/quic-go v0.50.1
+	github.com/youmark/pkcs8 v0.0.0-20240726163527-a2c0da244d78
 	go.osspkg.com/algorithms v1.5.0
 	go.osspkg.com/casecheck v0.3.0
 	go.osspkg.com/do v0.1.3
@@ -13,6 +14,7 @@ require (
 	go.osspkg.com/syncing v0.3.1
 	go.osspkg.com/xc v0.4.0
 	golang.org/x/sys v0.31.0
+	software.sslmate.com/src/go-pkcs12 v0.5.0
 )
 
 require (
@@ -0,0 +1,199 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package internal
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/youmark/pkcs8"
+	"go.osspkg.com/errors"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+var (
+	ErrCertSource      = errors.New("read certificate source")
+	ErrCertAmbiguous   = errors.New("certificate source is set more than once")
+	ErrCertNoProvider  = errors.New("certificate provider is not set")
+	ErrKeyPassphrase   = errors.New("private key is encrypted, passphrase required")
+	ErrKeyDecrypt      = errors.New("decrypt private key")
+	ErrKeyPair         = errors.New("load key pair")
+	ErrPKCS12          = errors.New("decode pkcs12 bundle")
+	ErrPKCS12NoKeyPair = errors.New("pkcs12 bundle has no matching certificate and key")
+	// ErrReadCA and ErrInvalidCA are shared by listen and client, so errors.Is
+	// matches them on both sides.
+	ErrReadCA    = errors.New("read ca certificate")
+	ErrInvalidCA = errors.New("invalid ca certificate pem")
+)
+
+// CertProvider fetches PEM or PKCS#12 data by name, e.g. from a secret store.
+// It is declared here for listen.Provider and client.Provider.
+type CertProvider interface {
+	Fetch(name string) ([]byte, error)
+}
+
+// CertSource is one PEM (or PKCS#12) blob that can come from a file, an inline
+// string, a byte slice or a named secret of a CertProvider.
+type CertSource struct {
+	File   string
+	PEM    string
+	Data   []byte
+	Secret string
+}
+
+func (s CertSource) IsEmpty() bool {
+	return len(s.File) == 0 && len(s.PEM) == 0 && len(s.Data) == 0 && len(s.Secret) == 0
+}
+
+func (s CertSource) Load(p CertProvider) ([]byte, error) {
+	count := 0
+	for _, ok := range []bool{len(s.File) > 0, len(s.PEM) > 0, len(s.Data) > 0, len(s.Secret) > 0} {
+		if ok {
+			count++
+		}
+	}
+	if count > 1 {
+		return nil, ErrCertAmbiguous
+	}
+
+	switch {
+	case len(s.Data) > 0:
+		return s.Data, nil
+	case len(s.PEM) > 0:
+		return []byte(s.PEM), nil
+	case len(s.File) > 0:
+		b, err := os.ReadFile(s.File)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrCertSource, s.File, err)
+		}
+		return b, nil
+	case len(s.Secret) > 0:
+		if p == nil {
+			return nil, fmt.Errorf("%w: %s", ErrCertNoProvider, s.Secret)
+		}
+		b, err := p.Fetch(s.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrCertSource, s.Secret, err)
+		}
+		return b, nil
+	default:
+		return nil, nil
+	}
+}
+
+// KeyPairSource describes a certificate with its private key, either as PEM
+// cert/key pair (the key may be an encrypted PKCS#8) or as a PKCS#12 bundle.
+type KeyPairSource struct {
+	Cert       CertSource
+	Key        CertSource
+	Passphrase string
+	PKCS12     CertSource
+}
+
+func (s KeyPairSource) IsEmpty() bool {
+	return s.Cert.IsEmpty() && s.Key.IsEmpty() && s.PKCS12.IsEmpty()
+}
+
+func (s KeyPairSource) Load(p CertProvider) (tls.Certificate, error) {
+	if !s.PKCS12.IsEmpty() {
+		if !s.Cert.IsEmpty() || !s.Key.IsEmpty() {
+			return tls.Certificate{}, ErrCertAmbiguous
+		}
+		b, err := s.PKCS12.Load(p)
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+		return ParsePKCS12(b, s.Passphrase)
+	}
+
+	certPEM, err := s.Cert.Load(p)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyPEM, err := s.Key.Load(p)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if len(keyPEM) == 0 {
+		keyPEM = certPEM
+	}
+	return ParseKeyPair(certPEM, keyPEM, s.Passphrase)
+}
+
+func ParseKeyPair(certPEM, keyPEM []byte, passphrase string) (tls.Certificate, error) {
+	decrypted, err := decryptKeyPEM(keyPEM, passphrase)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	cert, err := tls.X509KeyPair(certPEM, decrypted)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("%w: %w", ErrKeyPair, err)
+	}
+	return cert, nil
+}
+
+func decryptKeyPEM(keyPEM []byte, passphrase string) ([]byte, error) {
+	rest := keyPEM
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return keyPEM, nil
+		}
+		if block.Type != "ENCRYPTED PRIVATE KEY" {
+			continue
+		}
+		if len(passphrase) == 0 {
+			return nil, ErrKeyPassphrase
+		}
+		key, err := pkcs8.ParsePKCS8PrivateKey(block.Bytes, []byte(passphrase))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrKeyDecrypt, err)
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrKeyDecrypt, err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+	}
+}
+
+// ParsePKCS12 decodes a PKCS#12 (.p12/.pfx) bundle, both the legacy 3DES/RC2 and
+// the PBES2 (AES) encryption of OpenSSL 3. The certificate matching the private key
+// becomes the leaf, the other certificates are sent as its chain.
+func ParsePKCS12(data []byte, passphrase string) (tls.Certificate, error) {
+	key, first, rest, err := pkcs12.DecodeChain(data, passphrase)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("%w: %w", ErrPKCS12, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return tls.Certificate{}, ErrPKCS12NoKeyPair
+	}
+	pub, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok {
+		return tls.Certificate{}, ErrPKCS12NoKeyPair
+	}
+
+	certs := append([]*x509.Certificate{first}, rest...)
+	for i, leaf := range certs {
+		if !pub.Equal(leaf.PublicKey) {
+			continue
+		}
+		cert := tls.Certificate{PrivateKey: key, Leaf: leaf, Certificate: [][]byte{leaf.Raw}}
+		for j, c := range certs {
+			if i != j {
+				cert.Certificate = append(cert.Certificate, c.Raw)
+			}
+		}
+		return cert, nil
+	}
+	return tls.Certificate{}, ErrPKCS12NoKeyPair
+}
@@ -14,13 +14,28 @@ const (
 	NetUDP  = "udp"
 	NetUNIX = "unix"
 	NetQUIC = "quic"
+
+	NetTCP4 = "tcp4"
+	NetTCP6 = "tcp6"
+	NetUDP4 = "udp4"
+	NetUDP6 = "udp6"
 )
 
+// IsTCP reports whether the network is tcp of any family.
+func IsTCP(network string) bool {
+	return network == NetTCP || network == NetTCP4 || network == NetTCP6
+}
+
+// IsUDP reports whether the network is udp of any family.
+func IsUDP(network string) bool {
+	return network == NetUDP || network == NetUDP4 || network == NetUDP6
+}
+
 func IsPassableNetwork(network string) error {
 	switch network {
-	case NetTCP, NetUDP, NetUNIX, NetQUIC:
+	case NetTCP, NetUDP, NetUNIX, NetQUIC, NetTCP4, NetTCP6, NetUDP4, NetUDP6:
 		return nil
 	default:
-		return fmt.Errorf("invalid network type, use: tcp, udp, unix, quic")
+		return fmt.Errorf("invalid network type, use: tcp, tcp4, tcp6, udp, udp4, udp6, unix, quic")
 	}
 }
@@ -0,0 +1,47 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.osspkg.com/errors"
+)
+
+var ErrUnknownOption = errors.New("unknown option")
+
+// SetOption parses value to the field of key, e.g. from the query of an endpoint.
+// A field is a pointer to string, bool, int, time.Duration, *bool or *int.
+func SetOption(fields map[string]any, key, value string) (err error) {
+	switch p := fields[key].(type) {
+	case *string:
+		*p = value
+	case *bool:
+		*p, err = strconv.ParseBool(value)
+	case **bool:
+		var v bool
+		if v, err = strconv.ParseBool(value); err == nil {
+			*p = &v
+		}
+	case *int:
+		*p, err = strconv.Atoi(value)
+	case **int:
+		var v int
+		if v, err = strconv.Atoi(value); err == nil {
+			*p = &v
+		}
+	case *time.Duration:
+		*p, err = time.ParseDuration(value)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownOption, key)
+	}
+	if err != nil {
+		return fmt.Errorf("option %s: %w", key, err)
+	}
+	return nil
+}
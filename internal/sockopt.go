@@ -0,0 +1,54 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package internal
+
+import (
+	"strings"
+	"syscall"
+
+	"go.osspkg.com/errors"
+)
+
+var (
+	ErrSockOpt = errors.New("socket option")
+)
+
+// SockOpt sets an option of a socket before bind or connect,
+// the network is the family of the socket, e.g. tcp4 or udp6.
+type SockOpt func(network string, fd uintptr) error
+
+// Control chains the options after prev, the result is set to
+// net.ListenConfig.Control or net.Dialer.Control.
+func Control(
+	prev func(network, address string, rc syscall.RawConn) error, opts ...SockOpt,
+) func(network, address string, rc syscall.RawConn) error {
+	return func(network, address string, rc syscall.RawConn) error {
+		if prev != nil {
+			if err := prev(network, address, rc); err != nil {
+				return err
+			}
+		}
+		var err error
+		if e := rc.Control(func(fd uintptr) {
+			for _, opt := range opts {
+				if err = opt(network, fd); err != nil {
+					return
+				}
+			}
+		}); e != nil {
+			return e
+		}
+		return err
+	}
+}
+
+func isSockTCP(network string) bool {
+	return strings.HasPrefix(network, NetTCP)
+}
+
+func isSockV6(network string) bool {
+	return strings.HasSuffix(network, "6")
+}
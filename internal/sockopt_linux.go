@@ -0,0 +1,140 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package internal
+
+import (
+	"fmt"
+	"math"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func setSockOpt(fd uintptr, level, opt, val int, name string) error {
+	if err := unix.SetsockoptInt(int(fd), level, opt, val); err != nil {
+		return fmt.Errorf("%w: set %s: %w", ErrSockOpt, name, err)
+	}
+	return nil
+}
+
+func sockBool(on bool) int {
+	if on {
+		return 1
+	}
+	return 0
+}
+
+// SockV6Only sets IPV6_V6ONLY of an IPv6 socket.
+func SockV6Only(on bool) SockOpt {
+	return func(network string, fd uintptr) error {
+		if !isSockV6(network) {
+			return nil
+		}
+		return setSockOpt(fd, unix.IPPROTO_IPV6, unix.IPV6_V6ONLY, sockBool(on), "IPV6_V6ONLY")
+	}
+}
+
+// SockReusePort sets SO_REUSEPORT, the sockets of one address share the load.
+func SockReusePort() SockOpt {
+	return func(_ string, fd uintptr) error {
+		return setSockOpt(fd, unix.SOL_SOCKET, unix.SO_REUSEPORT, 1, "SO_REUSEPORT")
+	}
+}
+
+// SockBuffers sets SO_RCVBUF and SO_SNDBUF, a zero size keeps the system default.
+// The kernel doubles the value and limits it by net.core.rmem_max and wmem_max.
+func SockBuffers(read, write int) SockOpt {
+	return func(_ string, fd uintptr) error {
+		if read > 0 {
+			if err := setSockOpt(fd, unix.SOL_SOCKET, unix.SO_RCVBUF, read, "SO_RCVBUF"); err != nil {
+				return err
+			}
+		}
+		if write > 0 {
+			return setSockOpt(fd, unix.SOL_SOCKET, unix.SO_SNDBUF, write, "SO_SNDBUF")
+		}
+		return nil
+	}
+}
+
+// SockFreeBind sets IP_FREEBIND or IPV6_FREEBIND, the address may be bound
+// before it is assigned to an interface.
+func SockFreeBind() SockOpt {
+	return func(network string, fd uintptr) error {
+		if isSockV6(network) {
+			return setSockOpt(fd, unix.IPPROTO_IPV6, unix.IPV6_FREEBIND, 1, "IPV6_FREEBIND")
+		}
+		return setSockOpt(fd, unix.IPPROTO_IP, unix.IP_FREEBIND, 1, "IP_FREEBIND")
+	}
+}
+
+// SockFastOpen sets TCP_FASTOPEN of a listener with the queue length of pending handshakes.
+func SockFastOpen(queue int) SockOpt {
+	return func(network string, fd uintptr) error {
+		if !isSockTCP(network) {
+			return nil
+		}
+		return setSockOpt(fd, unix.IPPROTO_TCP, unix.TCP_FASTOPEN, queue, "TCP_FASTOPEN")
+	}
+}
+
+// SockFastOpenConnect sets TCP_FASTOPEN_CONNECT, the first write of a client
+// is sent in the SYN when the server cookie is known.
+func SockFastOpenConnect() SockOpt {
+	return func(network string, fd uintptr) error {
+		if !isSockTCP(network) {
+			return nil
+		}
+		return setSockOpt(fd, unix.IPPROTO_TCP, unix.TCP_FASTOPEN_CONNECT, 1, "TCP_FASTOPEN_CONNECT")
+	}
+}
+
+// SockDeferAccept sets TCP_DEFER_ACCEPT, a connection is accepted when data
+// arrives or the timeout, rounded up to seconds, expires.
+func SockDeferAccept(timeout time.Duration) SockOpt {
+	sec := int(math.Ceil(timeout.Seconds()))
+	return func(network string, fd uintptr) error {
+		if !isSockTCP(network) {
+			return nil
+		}
+		return setSockOpt(fd, unix.IPPROTO_TCP, unix.TCP_DEFER_ACCEPT, sec, "TCP_DEFER_ACCEPT")
+	}
+}
+
+// SockBacklog calls listen once more with the backlog, Linux resizes the queue
+// of a listening socket. The Go runtime listens with net.core.somaxconn.
+func SockBacklog(rc syscall.RawConn, backlog int) error {
+	var err error
+	if e := rc.Control(func(fd uintptr) {
+		err = unix.Listen(int(fd), backlog)
+	}); e != nil {
+		return e
+	}
+	if err != nil {
+		return fmt.Errorf("%w: set backlog: %w", ErrSockOpt, err)
+	}
+	return nil
+}
+
+// CloseOnExec keeps an inherited descriptor from the children of the process.
+func CloseOnExec(fd uintptr) {
+	unix.CloseOnExec(int(fd))
+}
+
+// PeerCred reads SO_PEERCRED of a unix connection, the process of the peer at connect.
+func PeerCred(rc syscall.RawConn) (pid, uid, gid int, err error) {
+	var cred *unix.Ucred
+	if e := rc.Control(func(fd uintptr) {
+		cred, err = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); e != nil {
+		return 0, 0, 0, e
+	}
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("%w: get SO_PEERCRED: %w", ErrSockOpt, err)
+	}
+	return int(cred.Pid), int(cred.Uid), int(cred.Gid), nil
+}
@@ -0,0 +1,40 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package internal_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.osspkg.com/casecheck"
+
+	"go.osspkg.com/network/internal"
+)
+
+func TestUnit_PeerCred(t *testing.T) {
+	l, err := net.Listen(internal.NetUNIX, filepath.Join(t.TempDir(), "peer.sock"))
+	casecheck.NoError(t, err)
+	defer l.Close() // nolint: errcheck
+
+	client, err := net.Dial(internal.NetUNIX, l.Addr().String())
+	casecheck.NoError(t, err)
+	defer client.Close() // nolint: errcheck
+
+	conn, err := l.Accept()
+	casecheck.NoError(t, err)
+	defer conn.Close() // nolint: errcheck
+
+	rc, err := conn.(*net.UnixConn).SyscallConn()
+	casecheck.NoError(t, err)
+
+	pid, uid, gid, err := internal.PeerCred(rc)
+	casecheck.NoError(t, err)
+	casecheck.Equal(t, os.Getpid(), pid)
+	casecheck.Equal(t, os.Getuid(), uid)
+	casecheck.Equal(t, os.Getgid(), gid)
+}
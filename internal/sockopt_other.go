@@ -0,0 +1,36 @@
+//go:build !linux
+
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package internal
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+func sockUnsupported(name string) SockOpt {
+	return func(string, uintptr) error {
+		return fmt.Errorf("%w: %s is not supported on %s", ErrSockOpt, name, runtime.GOOS)
+	}
+}
+
+func SockV6Only(bool) SockOpt                { return sockUnsupported("IPV6_V6ONLY") }
+func SockReusePort() SockOpt                 { return sockUnsupported("SO_REUSEPORT") }
+func SockBuffers(int, int) SockOpt           { return sockUnsupported("SO_RCVBUF and SO_SNDBUF") }
+func SockFreeBind() SockOpt                  { return sockUnsupported("IP_FREEBIND") }
+func SockFastOpen(int) SockOpt               { return sockUnsupported("TCP_FASTOPEN") }
+func SockFastOpenConnect() SockOpt           { return sockUnsupported("TCP_FASTOPEN_CONNECT") }
+func SockDeferAccept(time.Duration) SockOpt  { return sockUnsupported("TCP_DEFER_ACCEPT") }
+func SockBacklog(syscall.RawConn, int) error { return sockUnsupported("backlog")("", 0) }
+
+func CloseOnExec(uintptr) {}
+
+func PeerCred(syscall.RawConn) (int, int, int, error) {
+	return 0, 0, 0, sockUnsupported("SO_PEERCRED")("", 0)
+}
@@ -0,0 +1,245 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package listen
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.osspkg.com/errors"
+
+	"go.osspkg.com/network/internal"
+)
+
+const (
+	// envHandoffFDs is the comma separated keys of the sockets from descriptor 3.
+	envHandoffFDs = "NETWORK_HANDOFF_FDS"
+	// envHandoffReady is the descriptor of the pipe which is written by HandoffReady.
+	envHandoffReady = "NETWORK_HANDOFF_READY"
+)
+
+var (
+	handedOff = &inheritedFiles{kind: "handoff socket", load: loadHandoff}
+)
+
+func loadHandoff(v *inheritedFiles) {
+	keys, ready := os.Getenv(envHandoffFDs), os.Getenv(envHandoffReady)
+	if len(ready) == 0 {
+		return
+	}
+	os.Unsetenv(envHandoffFDs)   // nolint: errcheck
+	os.Unsetenv(envHandoffReady) // nolint: errcheck
+
+	var list []string
+	if len(keys) > 0 {
+		list = strings.Split(keys, ",")
+	}
+	fd, err := strconv.Atoi(ready)
+	if err != nil || fd != listenFDsStart+len(list) {
+		v.err = fmt.Errorf("invalid %s %q", envHandoffReady, ready)
+		return
+	}
+	v.notify = inheritFile(fd, "handoff-ready")
+
+	for i, key := range list {
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			v.err = fmt.Errorf("invalid %s: %w", envHandoffFDs, err)
+			return
+		}
+		v.files = append(v.files, inheritFile(listenFDsStart+i, name))
+	}
+}
+
+// Handoff takes the sockets of the key passed by the previous process,
+// see StartHandoff. ErrNotInherited is returned if there is no socket of the key.
+// The address is the path of a unix socket created by New, the file is removed
+// on close as by New with the options, it is empty for an inherited socket.
+func Handoff(network, address, key string, ssl *SSL, opts ...Option) (io.Closer, error) {
+	files, err := handedOff.take(key)
+	if err != nil {
+		return nil, err
+	}
+	l, err := handoffListener(network, address, files, ssl, opts)
+	if err != nil {
+		return nil, err
+	}
+	// the previous process stops accepting when the last socket is taken
+	if handedOff.taken() {
+		if err = HandoffReady(); err != nil {
+			return nil, errors.Wrap(err, l.Close())
+		}
+	}
+	return l, nil
+}
+
+func handoffListener(network, address string, files []*os.File, ssl *SSL, opts []Option) (io.Closer, error) {
+	if network != internal.NetUNIX || len(address) == 0 || IsAbstract(address) || len(files) != 1 {
+		return fromFiles(network, files, ssl)
+	}
+
+	l, err := fromFiles(network, files, nil)
+	if err != nil {
+		return nil, err
+	}
+	ul, ok := l.(*net.UnixListener)
+	if !ok {
+		return nil, errors.Wrap(fmt.Errorf("unix listener is %T", l), l.Close())
+	}
+	v := &unixListener{UnixListener: ul, path: address}
+	if v.file, err = os.Stat(address); err != nil {
+		return nil, errors.Wrap(err, v.Close())
+	}
+	v.remove.Store(!newOptions(opts).unix.KeepFile)
+	return withTLS(v, ssl)
+}
+
+// HandoffReady tells the previous process that the listeners are taken and it
+// may stop accepting, the sockets which are not taken are closed. Handoff calls
+// it when the last socket is taken, the process calls it once its listeners are
+// built if it does not take all of them. It does nothing if the process is not
+// started by StartHandoff or is ready already.
+func HandoffReady() error {
+	handedOff.once.Do(func() { handedOff.load(handedOff) })
+	if handedOff.err != nil {
+		return handedOff.err
+	}
+
+	handedOff.mux.Lock()
+	defer handedOff.mux.Unlock()
+
+	for i, f := range handedOff.files {
+		if f != nil {
+			f.Close() // nolint: errcheck
+			handedOff.files[i] = nil
+		}
+	}
+	if handedOff.notify == nil {
+		return nil
+	}
+	_, err := handedOff.notify.Write([]byte{1})
+	err = errors.Wrap(err, handedOff.notify.Close())
+	handedOff.notify = nil
+	return err
+}
+
+// StartHandoff starts cmd with the files passed from descriptor 3 under the keys,
+// one key may have several files, and waits until the new process calls
+// HandoffReady. The new process is killed if it is not ready in the timeout.
+func StartHandoff(cmd *exec.Cmd, keys []string, files []*os.File, timeout time.Duration) error {
+	if len(keys) != len(files) {
+		return fmt.Errorf("handoff has %d keys for %d files", len(keys), len(files))
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close() // nolint: errcheck
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = make([]string, 0, len(env)+2)
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, envHandoffFDs+"=") && !strings.HasPrefix(kv, envHandoffReady+"=") {
+			cmd.Env = append(cmd.Env, kv)
+		}
+	}
+	encoded := make([]string, 0, len(keys))
+	for _, key := range keys {
+		encoded = append(encoded, url.QueryEscape(key))
+	}
+	cmd.Env = append(cmd.Env,
+		envHandoffFDs+"="+strings.Join(encoded, ","),
+		envHandoffReady+"="+strconv.Itoa(listenFDsStart+len(files)),
+	)
+	cmd.ExtraFiles = append(append(make([]*os.File, 0, len(files)+1), files...), w)
+
+	err = cmd.Start()
+	// the new process has its copy, EOF is read if it exits
+	w.Close() // nolint: errcheck
+	if err != nil {
+		return fmt.Errorf("start new process: %w", err)
+	}
+
+	if err = r.SetReadDeadline(time.Now().Add(timeout)); err == nil {
+		_, err = r.Read(make([]byte, 1))
+	}
+	if err != nil {
+		err = errors.Wrap(err, cmd.Process.Kill())
+		cmd.Wait() // nolint: errcheck
+		return fmt.Errorf("new process is not ready: %w", err)
+	}
+	go cmd.Wait() // nolint: errcheck
+	return nil
+}
+
+// Files returns the copies of the sockets of a listener created by this package,
+// they are passed to StartHandoff.
+func Files(l io.Closer) ([]*os.File, error) {
+	switch v := l.(type) {
+	case *tlsListener:
+		return Files(v.Listener)
+	case *noDelayListener:
+		return Files(v.TCPListener)
+	case *multiListener:
+		var files []*os.File
+		for _, item := range v.list {
+			list, err := Files(item)
+			if err != nil {
+				for _, f := range files {
+					err = errors.Wrap(err, f.Close())
+				}
+				return nil, err
+			}
+			files = append(files, list...)
+		}
+		return files, nil
+	case *unixListener:
+		return fileOf(v.UnixListener)
+	case *net.UnixListener:
+		return fileOf(v)
+	case *net.TCPListener:
+		return fileOf(v)
+	case *net.UDPConn:
+		return fileOf(v)
+	default:
+		return nil, fmt.Errorf("listener %T cannot be passed to a new process", l)
+	}
+}
+
+// HandedOff keeps the socket file of a unix listener on close after StartHandoff
+// succeeded, the file is removed by the new process which serves it.
+func HandedOff(l io.Closer) {
+	switch v := l.(type) {
+	case *tlsListener:
+		HandedOff(v.Listener)
+	case *multiListener:
+		for _, item := range v.list {
+			HandedOff(item)
+		}
+	case *unixListener:
+		v.remove.Store(false)
+	case *net.UnixListener:
+		v.SetUnlinkOnClose(false)
+	}
+}
+
+func fileOf(v interface{ File() (*os.File, error) }) ([]*os.File, error) {
+	f, err := v.File()
+	if err != nil {
+		return nil, err
+	}
+	return []*os.File{f}, nil
+}
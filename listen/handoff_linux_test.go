@@ -0,0 +1,126 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package listen_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.osspkg.com/casecheck"
+
+	"go.osspkg.com/network/listen"
+)
+
+// TestUnit_HandoffUnix runs itself as the new process which takes the unix
+// socket, serves connections and removes the socket file on close. The previous
+// process is ready when the tcp socket is taken too.
+func TestUnit_HandoffUnix(t *testing.T) {
+	if child := os.Getenv("TEST_HANDOFF_CHILD"); len(child) > 0 {
+		if child == "fail" {
+			// exits without HandoffReady
+			return
+		}
+		lis, err := listen.Handoff("unix", child, "app", nil)
+		casecheck.NoError(t, err)
+		l := lis.(net.Listener)
+		serve := func() {
+			conn, err := l.Accept()
+			casecheck.NoError(t, err)
+			_, err = conn.Write([]byte("ok"))
+			casecheck.NoError(t, err)
+			// the previous process closes the connection
+			_, err = io.ReadAll(conn)
+			casecheck.NoError(t, err)
+			casecheck.NoError(t, conn.Close())
+		}
+		serve()
+		web, err := listen.Handoff("tcp", "", "web", nil)
+		casecheck.NoError(t, err)
+		casecheck.NoError(t, web.Close())
+		serve()
+		casecheck.NoError(t, l.Close())
+		return
+	}
+
+	path := filepath.Join(t.TempDir(), "app.sock")
+	// the mode binds the socket to a temporary name, the new process gets the path
+	unix := listen.Unix{Mode: "0600"}
+	web, err := net.Listen("tcp", "127.0.0.1:0")
+	casecheck.NoError(t, err)
+	defer web.Close() // nolint: errcheck
+
+	handoff := func(child string, starting func(done <-chan error)) error {
+		lis, err := listen.New(context.TODO(), "unix", path, nil, unix.Options()...)
+		casecheck.NoError(t, err)
+		defer lis.Close() // nolint: errcheck
+		files, err := listen.Files(lis)
+		casecheck.NoError(t, err)
+		webFiles, err := listen.Files(web)
+		casecheck.NoError(t, err)
+		files = append(files, webFiles...)
+		defer func() {
+			for _, f := range files {
+				f.Close() // nolint: errcheck
+			}
+		}()
+
+		cmd := exec.Command(os.Args[0], "-test.run=^TestUnit_HandoffUnix$")
+		cmd.Env = append(os.Environ(), "TEST_HANDOFF_CHILD="+child)
+		done := make(chan error, 1)
+		go func() {
+			done <- listen.StartHandoff(cmd, []string{"app", "web"}, files, 10*time.Second)
+		}()
+		if starting != nil {
+			starting(done)
+		}
+		if err = <-done; err != nil {
+			return err
+		}
+		listen.HandedOff(lis)
+		return nil
+	}
+
+	// the listener removes its socket file after a failed handoff
+	casecheck.Error(t, handoff("fail", nil))
+	_, err = os.Stat(path)
+	casecheck.True(t, os.IsNotExist(err), err)
+
+	casecheck.NoError(t, handoff(path, func(done <-chan error) {
+		conn := readOK(t, path)
+		defer conn.Close() // nolint: errcheck
+		select {
+		case err := <-done:
+			t.Fatalf("ready before the tcp socket is taken: %v", err)
+		default:
+		}
+	}))
+	// the closed listener keeps the socket file of the new process
+	casecheck.NoError(t, readOK(t, path).Close())
+
+	for i := 0; i < 100; i++ {
+		if _, err = os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	casecheck.True(t, os.IsNotExist(err), err)
+}
+
+func readOK(t *testing.T, path string) net.Conn {
+	conn, err := net.Dial("unix", path)
+	casecheck.NoError(t, err)
+	b := make([]byte, 2)
+	_, err = io.ReadFull(conn, b)
+	casecheck.NoError(t, err)
+	casecheck.Equal(t, "ok", string(b))
+	return conn
+}
@@ -0,0 +1,198 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package listen
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.osspkg.com/errors"
+
+	"go.osspkg.com/network/internal"
+)
+
+const (
+	envListenPID     = "LISTEN_PID"
+	envListenFDs     = "LISTEN_FDS"
+	envListenFDNames = "LISTEN_FDNAMES"
+	// listenFDsStart is the first descriptor passed by systemd, see sd_listen_fds(3).
+	listenFDsStart = 3
+	// listenFDDefaultName is the name of a socket without FileDescriptorName.
+	listenFDDefaultName = "unknown"
+)
+
+var (
+	ErrNotInherited = errors.New("inherited listener not found")
+
+	activated = &inheritedFiles{kind: "systemd socket", load: loadSystemd}
+)
+
+// inheritedFiles keeps the inherited sockets until they are taken,
+// the environment is read once by load and cleared, as sd_listen_fds does.
+type inheritedFiles struct {
+	kind  string
+	load  func(v *inheritedFiles)
+	once  sync.Once
+	mux   sync.Mutex
+	files []*os.File
+	// notify is the pipe of the ready signal of a handoff.
+	notify *os.File
+	err    error
+}
+
+func loadSystemd(v *inheritedFiles) {
+	pid := os.Getenv(envListenPID)
+	if len(pid) == 0 {
+		return
+	}
+	count, names := os.Getenv(envListenFDs), os.Getenv(envListenFDNames)
+	for _, key := range []string{envListenPID, envListenFDs, envListenFDNames} {
+		os.Unsetenv(key) // nolint: errcheck
+	}
+	if p, err := strconv.Atoi(pid); err != nil || p != os.Getpid() {
+		// the variables are set for another process
+		return
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 0 {
+		v.err = fmt.Errorf("invalid %s %q", envListenFDs, count)
+		return
+	}
+
+	list := strings.Split(names, ":")
+	for i := 0; i < n; i++ {
+		name := listenFDDefaultName
+		if i < len(list) && len(list[i]) > 0 {
+			name = list[i]
+		}
+		v.files = append(v.files, inheritFile(listenFDsStart+i, name))
+	}
+}
+
+func inheritFile(fd int, name string) *os.File {
+	internal.CloseOnExec(uintptr(fd))
+	return os.NewFile(uintptr(fd), name)
+}
+
+// take returns the sockets of the name, every socket is taken once.
+func (v *inheritedFiles) take(name string) ([]*os.File, error) {
+	v.once.Do(func() { v.load(v) })
+	if v.err != nil {
+		return nil, v.err
+	}
+
+	v.mux.Lock()
+	defer v.mux.Unlock()
+
+	var files []*os.File
+	for i, f := range v.files {
+		if f != nil && f.Name() == name {
+			files = append(files, f)
+			v.files[i] = nil
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w: %s %q", ErrNotInherited, v.kind, name)
+	}
+	return files, nil
+}
+
+// taken reports whether all sockets are taken.
+func (v *inheritedFiles) taken() bool {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	for _, f := range v.files {
+		if f != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Systemd takes the sockets passed by systemd socket activation with the
+// FileDescriptorName, several sockets of one name are served as one listener.
+// A socket without the name is named "unknown". ErrNotInherited is returned
+// if the process is not activated or has no socket of the name.
+func Systemd(network, name string, ssl *SSL) (io.Closer, error) {
+	files, err := activated.take(name)
+	if err != nil {
+		return nil, err
+	}
+	return fromFiles(network, files, ssl)
+}
+
+// FD takes the socket inherited from the parent process by its descriptor number.
+func FD(network string, fd uintptr, ssl *SSL) (io.Closer, error) {
+	return fromFiles(network, []*os.File{os.NewFile(fd, "fd"+strconv.Itoa(int(fd)))}, ssl)
+}
+
+// fromFiles creates the listener of the network from the sockets, they are
+// duplicated by the net package and the files are closed.
+func fromFiles(network string, files []*os.File, ssl *SSL) (io.Closer, error) {
+	defer func() {
+		for _, f := range files {
+			f.Close() // nolint: errcheck
+		}
+	}()
+
+	switch {
+	case internal.IsUDP(network):
+		if len(files) > 1 {
+			return nil, fmt.Errorf("%d sockets are inherited for one %s listener", len(files), network)
+		}
+		pc, err := net.FilePacketConn(files[0])
+		if err != nil {
+			return nil, fmt.Errorf("inherit %s: %w", files[0].Name(), err)
+		}
+		if _, ok := pc.(*net.UDPConn); !ok {
+			return nil, errors.Wrap(fmt.Errorf("inherited %s is not a %s socket", files[0].Name(), network), pc.Close())
+		}
+		return pc, nil
+
+	case internal.IsTCP(network), network == internal.NetUNIX:
+		list := make([]net.Listener, 0, len(files))
+		closeAll := func(err error) error {
+			for _, l := range list {
+				err = errors.Wrap(err, l.Close())
+			}
+			return err
+		}
+		for _, f := range files {
+			l, err := net.FileListener(f)
+			if err != nil {
+				return nil, closeAll(fmt.Errorf("inherit %s: %w", f.Name(), err))
+			}
+			list = append(list, l)
+			if !isNetworkListener(network, l) {
+				return nil, closeAll(fmt.Errorf("inherited %s is not a %s socket", f.Name(), network))
+			}
+		}
+		var l net.Listener = list[0]
+		if len(list) > 1 {
+			l = newMultiListener(list)
+		}
+		return withTLS(l, ssl)
+
+	default:
+		return nil, fmt.Errorf("inherited listeners are supported only for tcp, unix and udp networks")
+	}
+}
+
+func isNetworkListener(network string, l net.Listener) bool {
+	switch l.(type) {
+	case *net.TCPListener:
+		return internal.IsTCP(network)
+	case *net.UnixListener:
+		return network == internal.NetUNIX
+	default:
+		return false
+	}
+}
@@ -0,0 +1,97 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package listen_test
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+
+	"go.osspkg.com/casecheck"
+	"golang.org/x/sys/unix"
+
+	"go.osspkg.com/network/listen"
+)
+
+func newFileListener(t *testing.T) (net.Listener, *os.File) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	casecheck.NoError(t, err)
+	f, err := l.(*net.TCPListener).File()
+	casecheck.NoError(t, err)
+	return l, f
+}
+
+// dupFD returns a descriptor of the listener which is owned by nobody, as an inherited one.
+func dupFD(t *testing.T, l net.Listener) uintptr {
+	f, err := l.(*net.TCPListener).File()
+	casecheck.NoError(t, err)
+	defer f.Close() // nolint: errcheck
+	fd, err := unix.Dup(int(f.Fd()))
+	casecheck.NoError(t, err)
+	return uintptr(fd)
+}
+
+func TestUnit_InheritFD(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	casecheck.NoError(t, err)
+	defer l.Close() // nolint: errcheck
+
+	_, err = listen.FD("udp", dupFD(t, l), nil)
+	casecheck.Error(t, err)
+
+	inherited, err := listen.FD("tcp", dupFD(t, l), nil)
+	casecheck.NoError(t, err)
+	il := inherited.(net.Listener)
+	casecheck.Equal(t, l.Addr().String(), il.Addr().String())
+
+	conn, err := net.Dial("tcp", il.Addr().String())
+	casecheck.NoError(t, err)
+	casecheck.NoError(t, conn.Close())
+	casecheck.NoError(t, il.Close())
+}
+
+// TestUnit_InheritSystemd runs itself as a child with the sockets from fd 3,
+// as systemd passes them, LISTEN_PID is set by the child to its pid.
+func TestUnit_InheritSystemd(t *testing.T) {
+	if os.Getenv("TEST_LISTEN_CHILD") == "1" {
+		casecheck.NoError(t, os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid())))
+
+		web, err := listen.Systemd("tcp", "web", nil)
+		casecheck.NoError(t, err)
+		casecheck.NoError(t, web.Close())
+
+		_, err = listen.Systemd("tcp", "web", nil)
+		casecheck.True(t, errors.Is(err, listen.ErrNotInherited), err)
+
+		dns, err := listen.Systemd("udp", "unknown", nil)
+		casecheck.NoError(t, err)
+		_, ok := dns.(net.PacketConn)
+		casecheck.True(t, ok)
+		casecheck.NoError(t, dns.Close())
+
+		casecheck.Equal(t, "", os.Getenv("LISTEN_FDS"))
+		return
+	}
+
+	l1, f1 := newFileListener(t)
+	defer l1.Close() // nolint: errcheck
+	l2, f2 := newFileListener(t)
+	defer l2.Close() // nolint: errcheck
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	casecheck.NoError(t, err)
+	defer pc.Close() // nolint: errcheck
+	f3, err := pc.(*net.UDPConn).File()
+	casecheck.NoError(t, err)
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestUnit_InheritSystemd$")
+	cmd.Env = append(os.Environ(), "TEST_LISTEN_CHILD=1", "LISTEN_FDS=3", "LISTEN_FDNAMES=web:web:")
+	cmd.ExtraFiles = []*os.File{f1, f2, f3}
+	out, err := cmd.CombinedOutput()
+	casecheck.NoError(t, err, string(out))
+}
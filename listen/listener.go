@@ -11,50 +11,112 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"strconv"
 
 	"github.com/quic-go/quic-go"
+	"go.osspkg.com/errors"
 
+	"go.osspkg.com/network/address"
 	"go.osspkg.com/network/internal"
 )
 
-func New(ctx context.Context, network, address string, ssl *SSL) (io.Closer, error) {
+// New listens the address of the network. The host may be a network interface
+// name or a family wildcard, see address.ResolveBind: a stream listener is bound
+// to every address of the interface, a packet one to the first address.
+// The options are not applied to quic.
+func New(ctx context.Context, network, addr string, ssl *SSL, opts ...Option) (io.Closer, error) {
 	switch network {
-	case internal.NetTCP:
-		return newListen(ctx, network, address, ssl)
-	case internal.NetUDP:
-		return newListenPacket(ctx, network, address)
+	case internal.NetTCP, internal.NetTCP4, internal.NetTCP6:
+		return newListen(ctx, network, addr, ssl, opts)
+	case internal.NetUDP, internal.NetUDP4, internal.NetUDP6:
+		return newListenPacket(ctx, network, addr, opts)
 	case internal.NetUNIX:
-		return newListen(ctx, network, address, nil)
+		return newListenUnix(ctx, addr, opts)
 	case internal.NetQUIC:
-		return newListenQUIC(ctx, address, ssl)
+		return newListenQUIC(ctx, addr, ssl)
 	default:
-		return nil, fmt.Errorf("invalid network type, use: tcp, udp, unix")
+		return nil, fmt.Errorf("invalid network type, use: tcp, tcp4, tcp6, udp, udp4, udp6, unix, quic")
 	}
 }
 
-func newListenPacket(ctx context.Context, network, address string) (net.PacketConn, error) {
-	var lc net.ListenConfig
-	return lc.ListenPacket(ctx, network, address)
+// bindAddrs expands the bind forms of the address.
+func bindAddrs(network, addr string) ([]string, error) {
+	return address.ResolveBind(network, addr)
 }
 
-func newListen(ctx context.Context, network, address string, ssl *SSL) (l net.Listener, err error) {
-	var lc net.ListenConfig
-	if l, err = lc.Listen(ctx, network, address); err != nil {
+func newListenPacket(ctx context.Context, network, addr string, opts []Option) (net.PacketConn, error) {
+	addrs, err := bindAddrs(network, addr)
+	if err != nil {
 		return nil, err
 	}
+	return newOptions(opts).lc.ListenPacket(ctx, network, addrs[0])
+}
+
+func newListen(ctx context.Context, network, addr string, ssl *SSL, opts []Option) (l net.Listener, err error) {
+	addrs, err := bindAddrs(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	o := newOptions(opts)
+	list := make([]net.Listener, 0, len(addrs))
+	for i, a := range addrs {
+		if i > 0 {
+			a = samePort(a, list[0].Addr())
+		}
+		if l, err = o.listen(ctx, network, a); err != nil {
+			for _, prev := range list {
+				err = errors.Wrap(err, prev.Close())
+			}
+			return nil, err
+		}
+		list = append(list, l)
+	}
+	if len(list) > 1 {
+		l = newMultiListener(list)
+	}
+	return withTLS(l, ssl)
+}
 
+// withTLS wraps the listener by TLS if the certificates are set.
+func withTLS(l net.Listener, ssl *SSL) (net.Listener, error) {
 	if ssl == nil || len(ssl.Certs) == 0 {
-		return
+		return l, nil
+	}
+	conf, err := NewTLSConfig(ssl)
+	if err != nil {
+		return nil, errors.Wrap(err, l.Close())
 	}
+	return &tlsListener{Listener: l, conf: conf}, nil
+}
 
-	var conf *tls.Config
-	if conf, err = NewTLSConfig(ssl); err != nil {
+// tlsListener is tls.NewListener which keeps the inner listener for Files.
+type tlsListener struct {
+	net.Listener
+	conf *tls.Config
+}
+
+func (v *tlsListener) Accept() (net.Conn, error) {
+	conn, err := v.Listener.Accept()
+	if err != nil {
 		return nil, err
 	}
-	return tls.NewListener(l, conf), nil
+	return tls.Server(conn, v.conf), nil
 }
 
-func newListenQUIC(_ context.Context, address string, ssl *SSL) (l *quic.Listener, err error) {
+// samePort replaces a dynamic port by the port of the first listener,
+// all addresses of an interface are served on one port.
+func samePort(addr string, first net.Addr) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || port != "0" {
+		return addr
+	}
+	if ta, ok := first.(*net.TCPAddr); ok {
+		return net.JoinHostPort(host, strconv.Itoa(ta.Port))
+	}
+	return addr
+}
+
+func newListenQUIC(_ context.Context, addr string, ssl *SSL) (l *quic.Listener, err error) {
 	if ssl == nil || len(ssl.Certs) == 0 {
 		return nil, fmt.Errorf("QUIC cant work without tls")
 	}
@@ -68,5 +130,9 @@ func newListenQUIC(_ context.Context, address string, ssl *SSL) (l *quic.Listene
 		return nil, err
 	}
 
-	return quic.ListenAddr(address, conf, &quic.Config{EnableDatagrams: true})
+	addrs, err := bindAddrs(internal.NetUDP, addr)
+	if err != nil {
+		return nil, err
+	}
+	return quic.ListenAddr(addrs[0], conf, &quic.Config{EnableDatagrams: true})
 }
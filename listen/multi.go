@@ -0,0 +1,82 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package listen
+
+import (
+	"net"
+	"sync"
+
+	"go.osspkg.com/errors"
+)
+
+type (
+	// multiListener accepts from the listeners of one bind address,
+	// e.g. of every address of a network interface.
+	multiListener struct {
+		list  []net.Listener
+		conns chan accepted
+		done  chan struct{}
+		once  sync.Once
+	}
+
+	accepted struct {
+		conn net.Conn
+		err  error
+	}
+)
+
+func newMultiListener(list []net.Listener) *multiListener {
+	v := &multiListener{
+		list:  list,
+		conns: make(chan accepted),
+		done:  make(chan struct{}),
+	}
+	for _, l := range list {
+		go v.accept(l)
+	}
+	return v
+}
+
+func (v *multiListener) accept(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		select {
+		case v.conns <- accepted{conn: conn, err: err}:
+		case <-v.done:
+			if conn != nil {
+				conn.Close() // nolint: errcheck
+			}
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (v *multiListener) Accept() (net.Conn, error) {
+	select {
+	case a := <-v.conns:
+		return a.conn, a.err
+	case <-v.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (v *multiListener) Close() (err error) {
+	v.once.Do(func() {
+		close(v.done)
+		for _, l := range v.list {
+			err = errors.Wrap(err, l.Close())
+		}
+	})
+	return
+}
+
+// Addr is the address of the first listener.
+func (v *multiListener) Addr() net.Addr {
+	return v.list[0].Addr()
+}
@@ -0,0 +1,101 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package listen
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"syscall"
+
+	"go.osspkg.com/errors"
+
+	"go.osspkg.com/network/internal"
+)
+
+type (
+	// Option changes the listen config, the socket options are set before bind.
+	Option func(o *options)
+
+	options struct {
+		lc net.ListenConfig
+		// backlog is set by listen once more after the listener is created.
+		backlog int
+		// noDelay is set to every accepted tcp connection.
+		noDelay *bool
+		// unix is the socket file of a unix listener.
+		unix Unix
+	}
+)
+
+// V6Only sets IPV6_V6ONLY of an IPv6 socket: true serves IPv6 only and false
+// also serves IPv4 on the same socket. Without it tcp6 and udp6 are IPv6 only
+// and tcp and udp are dual-stack, as the Go runtime sets them.
+func V6Only(on bool) Option {
+	return control(internal.SockV6Only(on))
+}
+
+// control chains the socket options after the previous control of the listen config.
+func control(opts ...internal.SockOpt) Option {
+	return func(o *options) {
+		o.lc.Control = internal.Control(o.lc.Control, opts...)
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// listen creates a listener and applies the options which are set after it.
+func (o *options) listen(ctx context.Context, network, addr string) (net.Listener, error) {
+	l, err := o.lc.Listen(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if o.backlog > 0 {
+		if err = setBacklog(l, o.backlog); err != nil {
+			return nil, errors.Wrap(err, l.Close())
+		}
+	}
+	if tl, ok := l.(*net.TCPListener); ok && o.noDelay != nil {
+		l = &noDelayListener{TCPListener: tl, noDelay: *o.noDelay}
+	}
+	return l, nil
+}
+
+func setBacklog(l net.Listener, backlog int) error {
+	sc, ok := l.(syscall.Conn)
+	if !ok {
+		return fmt.Errorf("backlog is not supported by %T", l)
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	return internal.SockBacklog(rc, backlog)
+}
+
+// noDelayListener sets TCP_NODELAY of accepted connections, the Go runtime
+// enables it on accept, so it cannot be inherited from the listening socket.
+type noDelayListener struct {
+	*net.TCPListener
+	noDelay bool
+}
+
+func (v *noDelayListener) Accept() (net.Conn, error) {
+	conn, err := v.AcceptTCP()
+	if err != nil {
+		return nil, err
+	}
+	if err = conn.SetNoDelay(v.noDelay); err != nil {
+		return nil, errors.Wrap(err, conn.Close())
+	}
+	return conn, nil
+}
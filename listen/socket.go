@@ -0,0 +1,137 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package listen
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"go.osspkg.com/network/internal"
+)
+
+// Socket tunes the listening socket, a zero field keeps the system default.
+// The options are set on Linux, on other systems a set field fails the listen.
+type Socket struct {
+	// ReusePort sets SO_REUSEPORT, several processes may listen the same address.
+	ReusePort bool `yaml:"reuse_port,omitempty"`
+	// ReadBuffer and WriteBuffer set SO_RCVBUF and SO_SNDBUF, accepted connections inherit them.
+	ReadBuffer  int `yaml:"read_buffer,omitempty"`
+	WriteBuffer int `yaml:"write_buffer,omitempty"`
+	// FreeBind sets IP_FREEBIND, the address may be bound before it is up.
+	FreeBind bool `yaml:"free_bind,omitempty"`
+
+	// Backlog is the queue length of accepted connections, the kernel limits it
+	// by net.core.somaxconn, the Go runtime uses that limit by default. TCP and unix only.
+	Backlog int `yaml:"backlog,omitempty"`
+
+	// NoDelay sets TCP_NODELAY of accepted connections, the Go runtime enables it by default.
+	NoDelay *bool `yaml:"no_delay,omitempty"`
+	// FastOpen sets TCP_FASTOPEN with the queue length of pending handshakes.
+	FastOpen int `yaml:"fast_open,omitempty"`
+	// DeferAccept sets TCP_DEFER_ACCEPT, a connection is accepted when its
+	// first data arrives, the timeout is rounded up to seconds.
+	DeferAccept time.Duration `yaml:"defer_accept,omitempty"`
+	// KeepAliveIdle, KeepAliveInterval and KeepAliveCount tune the keepalive
+	// probes of accepted connections, see net.KeepAliveConfig for the defaults.
+	KeepAliveIdle     time.Duration `yaml:"keepalive_idle,omitempty"`
+	KeepAliveInterval time.Duration `yaml:"keepalive_interval,omitempty"`
+	KeepAliveCount    int           `yaml:"keepalive_count,omitempty"`
+	// DisableKeepAlive turns the keepalive probes off.
+	DisableKeepAlive bool `yaml:"disable_keepalive,omitempty"`
+}
+
+// Validate checks the values and that the options fit the network.
+func (s Socket) Validate(network string) error {
+	switch {
+	case s.ReadBuffer < 0 || s.WriteBuffer < 0:
+		return fmt.Errorf("socket buffer size must be positive")
+	case s.Backlog < 0:
+		return fmt.Errorf("socket backlog must be positive")
+	case s.FastOpen < 0:
+		return fmt.Errorf("socket fast open queue must be positive")
+	case s.DeferAccept < 0:
+		return fmt.Errorf("socket defer accept must be positive")
+	case s.KeepAliveIdle < 0 || s.KeepAliveInterval < 0 || s.KeepAliveCount < 0:
+		return fmt.Errorf("socket keepalive must be positive")
+	case s.DisableKeepAlive && s.keepAlive():
+		return fmt.Errorf("socket keepalive is disabled and tuned at the same time")
+	}
+
+	tcp, udp := internal.IsTCP(network), internal.IsUDP(network)
+	switch {
+	case network == internal.NetQUIC:
+		return fmt.Errorf("socket options are not supported for quic network")
+	case !tcp && !udp && (s.ReusePort || s.FreeBind):
+		return fmt.Errorf("socket reuse port and free bind are supported only for tcp and udp networks")
+	case !tcp && network != internal.NetUNIX && s.Backlog > 0:
+		return fmt.Errorf("socket backlog is supported only for tcp and unix networks")
+	case !tcp && (s.NoDelay != nil || s.FastOpen > 0 || s.DeferAccept > 0 || s.keepAlive() || s.DisableKeepAlive):
+		return fmt.Errorf("socket tcp options are supported only for tcp networks")
+	}
+	return nil
+}
+
+// SetOption sets the field by its yaml name, e.g. from the query of an endpoint.
+func (s *Socket) SetOption(key, value string) error {
+	return internal.SetOption(map[string]any{
+		"reuse_port":         &s.ReusePort,
+		"read_buffer":        &s.ReadBuffer,
+		"write_buffer":       &s.WriteBuffer,
+		"free_bind":          &s.FreeBind,
+		"backlog":            &s.Backlog,
+		"no_delay":           &s.NoDelay,
+		"fast_open":          &s.FastOpen,
+		"defer_accept":       &s.DeferAccept,
+		"keepalive_idle":     &s.KeepAliveIdle,
+		"keepalive_interval": &s.KeepAliveInterval,
+		"keepalive_count":    &s.KeepAliveCount,
+		"disable_keepalive":  &s.DisableKeepAlive,
+	}, key, value)
+}
+
+func (s Socket) keepAlive() bool {
+	return s.KeepAliveIdle > 0 || s.KeepAliveInterval > 0 || s.KeepAliveCount > 0
+}
+
+// Options converts the socket config to the listen options.
+func (s Socket) Options() []Option {
+	var sock []internal.SockOpt
+	if s.ReusePort {
+		sock = append(sock, internal.SockReusePort())
+	}
+	if s.ReadBuffer > 0 || s.WriteBuffer > 0 {
+		sock = append(sock, internal.SockBuffers(s.ReadBuffer, s.WriteBuffer))
+	}
+	if s.FreeBind {
+		sock = append(sock, internal.SockFreeBind())
+	}
+	if s.FastOpen > 0 {
+		sock = append(sock, internal.SockFastOpen(s.FastOpen))
+	}
+	if s.DeferAccept > 0 {
+		sock = append(sock, internal.SockDeferAccept(s.DeferAccept))
+	}
+
+	opts := []Option{func(o *options) {
+		o.backlog, o.noDelay = s.Backlog, s.NoDelay
+		switch {
+		case s.DisableKeepAlive:
+			o.lc.KeepAlive = -1
+		case s.keepAlive():
+			o.lc.KeepAliveConfig = net.KeepAliveConfig{
+				Enable:   true,
+				Idle:     s.KeepAliveIdle,
+				Interval: s.KeepAliveInterval,
+				Count:    s.KeepAliveCount,
+			}
+		}
+	}}
+	if len(sock) > 0 {
+		opts = append(opts, control(sock...))
+	}
+	return opts
+}
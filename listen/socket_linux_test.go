@@ -0,0 +1,121 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package listen_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.osspkg.com/casecheck"
+	"golang.org/x/sys/unix"
+
+	"go.osspkg.com/network/client"
+	"go.osspkg.com/network/listen"
+)
+
+func getSockOpt(t *testing.T, c syscall.Conn, level, opt int) int {
+	rc, err := c.SyscallConn()
+	casecheck.NoError(t, err)
+	var val int
+	casecheck.NoError(t, rc.Control(func(fd uintptr) {
+		val, err = unix.GetsockoptInt(int(fd), level, opt)
+	}))
+	casecheck.NoError(t, err)
+	return val
+}
+
+func TestUnit_SocketOptions(t *testing.T) {
+	noDelay := false
+	sock := listen.Socket{
+		ReusePort:     true,
+		ReadBuffer:    64 << 10,
+		WriteBuffer:   64 << 10,
+		FreeBind:      true,
+		Backlog:       16,
+		NoDelay:       &noDelay,
+		FastOpen:      8,
+		DeferAccept:   1500 * time.Millisecond,
+		KeepAliveIdle: 30 * time.Second,
+	}
+	casecheck.NoError(t, sock.Validate("tcp4"))
+
+	lis, err := listen.New(context.TODO(), "tcp4", "127.0.0.1:0", nil, sock.Options()...)
+	casecheck.NoError(t, err)
+	l := lis.(net.Listener)
+	defer l.Close() // nolint: errcheck
+
+	sl := l.(syscall.Conn)
+	casecheck.Equal(t, 1, getSockOpt(t, sl, unix.SOL_SOCKET, unix.SO_REUSEPORT))
+	casecheck.True(t, getSockOpt(t, sl, unix.SOL_SOCKET, unix.SO_RCVBUF) >= 64<<10)
+	casecheck.Equal(t, 1, getSockOpt(t, sl, unix.IPPROTO_IP, unix.IP_FREEBIND))
+	casecheck.Equal(t, 8, getSockOpt(t, sl, unix.IPPROTO_TCP, unix.TCP_FASTOPEN))
+	casecheck.True(t, getSockOpt(t, sl, unix.IPPROTO_TCP, unix.TCP_DEFER_ACCEPT) >= 2)
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, e := l.Accept()
+		if e != nil {
+			close(accepted)
+			return
+		}
+		accepted <- conn
+	}()
+
+	cliNoDelay := false
+	cli, err := client.New(client.Config{
+		Network: "tcp4",
+		Address: l.Addr().String(),
+		Socket: &client.Socket{
+			WriteBuffer:       64 << 10,
+			NoDelay:           &cliNoDelay,
+			KeepAliveInterval: 5 * time.Second,
+		},
+	})
+	casecheck.NoError(t, err)
+
+	casecheck.NoError(t, cli.Call(context.TODO(), func(_ context.Context, w io.Writer, _ io.Reader) error {
+		sc := w.(syscall.Conn)
+		casecheck.True(t, getSockOpt(t, sc, unix.SOL_SOCKET, unix.SO_SNDBUF) >= 64<<10)
+		casecheck.Equal(t, 0, getSockOpt(t, sc, unix.IPPROTO_TCP, unix.TCP_NODELAY))
+		casecheck.Equal(t, 1, getSockOpt(t, sc, unix.SOL_SOCKET, unix.SO_KEEPALIVE))
+		casecheck.Equal(t, 5, getSockOpt(t, sc, unix.IPPROTO_TCP, unix.TCP_KEEPINTVL))
+
+		// the connection is accepted after the first data because of TCP_DEFER_ACCEPT
+		_, e := w.Write([]byte("ping"))
+		casecheck.NoError(t, e)
+
+		conn, ok := <-accepted
+		casecheck.True(t, ok)
+		defer conn.Close() // nolint: errcheck
+
+		ac := conn.(syscall.Conn)
+		casecheck.Equal(t, 0, getSockOpt(t, ac, unix.IPPROTO_TCP, unix.TCP_NODELAY))
+		casecheck.Equal(t, 1, getSockOpt(t, ac, unix.SOL_SOCKET, unix.SO_KEEPALIVE))
+		casecheck.Equal(t, 30, getSockOpt(t, ac, unix.IPPROTO_TCP, unix.TCP_KEEPIDLE))
+		return nil
+	}))
+}
+
+func TestUnit_SocketValidate(t *testing.T) {
+	noDelay := true
+	for network, sock := range map[string]listen.Socket{
+		"tcp":  {ReadBuffer: -1},
+		"tcp4": {Backlog: -1},
+		"tcp6": {KeepAliveIdle: time.Second, DisableKeepAlive: true},
+		"udp":  {NoDelay: &noDelay},
+		"udp4": {Backlog: 10},
+		"unix": {ReusePort: true},
+		"quic": {ReadBuffer: 1024},
+	} {
+		casecheck.Error(t, sock.Validate(network), network)
+	}
+	casecheck.NoError(t, listen.Socket{ReusePort: true, ReadBuffer: 1024}.Validate("udp"))
+	casecheck.NoError(t, listen.Socket{Backlog: 10}.Validate("unix"))
+}
@@ -12,6 +12,7 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"fmt"
 	"math/big"
 	"net"
 	"os"
@@ -20,17 +21,53 @@ import (
 	"go.osspkg.com/network/internal"
 )
 
+var (
+	ErrReadCA    = internal.ErrReadCA
+	ErrInvalidCA = internal.ErrInvalidCA
+)
+
 type SSL struct {
-	Certs      []Certificate
-	NextProtos []string
+	Certs      []Certificate `yaml:"certs,omitempty"`
+	NextProtos []string      `yaml:"next_protos,omitempty"`
 }
 
+// Provider fetches PEM or PKCS#12 data by name, e.g. from a secret store,
+// for the *Secret fields of a Certificate.
+type Provider = internal.CertProvider
+
 type Certificate struct {
 	CAFile       string   `yaml:"ca_file"`
 	CertFile     string   `yaml:"cert_file"`
 	KeyFile      string   `yaml:"key_file"`
+	CAPEM        string   `yaml:"ca_pem,omitempty"`
+	CertPEM      string   `yaml:"cert_pem,omitempty"`
+	KeyPEM       string   `yaml:"key_pem,omitempty"`
+	CASecret     string   `yaml:"ca_secret,omitempty"`
+	CertSecret   string   `yaml:"cert_secret,omitempty"`
+	KeySecret    string   `yaml:"key_secret,omitempty"`
+	PKCS12File   string   `yaml:"pkcs12_file,omitempty"`
+	PKCS12Secret string   `yaml:"pkcs12_secret,omitempty"`
+	Passphrase   string   `yaml:"passphrase,omitempty"`
+	CAData       []byte   `yaml:"-"`
+	CertData     []byte   `yaml:"-"`
+	KeyData      []byte   `yaml:"-"`
+	PKCS12Data   []byte   `yaml:"-"`
 	Addresses    []string `yaml:"addresses"`
 	AutoGenerate bool     `yaml:"auto_generate"`
+	Provider     Provider `yaml:"-"`
+}
+
+func (c Certificate) keyPair() internal.KeyPairSource {
+	return internal.KeyPairSource{
+		Cert:       internal.CertSource{File: c.CertFile, PEM: c.CertPEM, Data: c.CertData, Secret: c.CertSecret},
+		Key:        internal.CertSource{File: c.KeyFile, PEM: c.KeyPEM, Data: c.KeyData, Secret: c.KeySecret},
+		PKCS12:     internal.CertSource{File: c.PKCS12File, Data: c.PKCS12Data, Secret: c.PKCS12Secret},
+		Passphrase: c.Passphrase,
+	}
+}
+
+func (c Certificate) ca() internal.CertSource {
+	return internal.CertSource{File: c.CAFile, PEM: c.CAPEM, Data: c.CAData, Secret: c.CASecret}
 }
 
 func NewTLSConfig(ssl *SSL) (*tls.Config, error) {
@@ -61,15 +98,24 @@ func NewTLSConfig(ssl *SSL) (*tls.Config, error) {
 }
 
 func parseCertificate(rootCA *x509.CertPool, c Certificate) (cert tls.Certificate, err error) {
-	if len(c.CertFile) > 0 || len(c.KeyFile) > 0 {
-		cert, err = tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if pair := c.keyPair(); !pair.IsEmpty() {
+		cert, err = pair.Load(c.Provider)
 		if err != nil {
 			return
 		}
 	}
-	if len(c.CAFile) > 0 {
-		if caCert, e := os.ReadFile(c.CAFile); e == nil {
-			rootCA.AppendCertsFromPEM(caCert)
+	if ca := c.ca(); !ca.IsEmpty() {
+		var b []byte
+		if b, err = ca.Load(c.Provider); err != nil {
+			err = fmt.Errorf("%w: %w", ErrReadCA, err)
+			return
+		}
+		if !rootCA.AppendCertsFromPEM(b) {
+			name := c.CAFile
+			if len(name) == 0 {
+				name = "inline"
+			}
+			err = fmt.Errorf("%w: %s", ErrInvalidCA, name)
 		}
 	}
 	return
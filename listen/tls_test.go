@@ -0,0 +1,28 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package listen_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"go.osspkg.com/casecheck"
+
+	"go.osspkg.com/network/client"
+	"go.osspkg.com/network/listen"
+)
+
+func TestUnit_TLSConfigCA(t *testing.T) {
+	_, err := listen.NewTLSConfig(&listen.SSL{Certs: []listen.Certificate{{CAPEM: "not a pem"}}})
+	casecheck.True(t, errors.Is(err, listen.ErrInvalidCA), err)
+	// the errors are the same on both sides
+	casecheck.True(t, errors.Is(err, client.ErrInvalidCA), err)
+
+	_, err = listen.NewTLSConfig(&listen.SSL{Certs: []listen.Certificate{{CAFile: filepath.Join(t.TempDir(), "ca.pem")}}})
+	casecheck.True(t, errors.Is(err, listen.ErrReadCA), err)
+	casecheck.True(t, errors.Is(err, client.ErrReadCA), err)
+}
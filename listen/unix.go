@@ -0,0 +1,216 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package listen
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"go.osspkg.com/errors"
+
+	"go.osspkg.com/network/internal"
+)
+
+// staleTimeout limits the dial which checks that a socket file is not used.
+const staleTimeout = time.Second
+
+// Unix is the socket file of a unix listener. An abstract socket, e.g. @name,
+// has no file, so the fields are not allowed for it.
+type Unix struct {
+	// Mode is the octal permissions of the file, e.g. "0660".
+	Mode string `yaml:"mode,omitempty"`
+	// UID and GID are the owner and the group of the file.
+	UID *int `yaml:"uid,omitempty"`
+	GID *int `yaml:"gid,omitempty"`
+	// KeepFile leaves the file on close, by default it is removed
+	// if it is still the file created by the listener.
+	KeepFile bool `yaml:"keep_file,omitempty"`
+}
+
+// Validate checks the values for the path of the socket.
+func (u Unix) Validate(path string) error {
+	if IsAbstract(path) && (len(u.Mode) > 0 || u.UID != nil || u.GID != nil || u.KeepFile) {
+		return fmt.Errorf("unix socket %s is abstract and has no file", path)
+	}
+	if _, err := u.mode(); err != nil {
+		return err
+	}
+	if (u.UID != nil && *u.UID < 0) || (u.GID != nil && *u.GID < 0) {
+		return fmt.Errorf("unix socket uid and gid must be positive")
+	}
+	return nil
+}
+
+// SetOption sets the field by its yaml name, e.g. from the query of an endpoint.
+func (u *Unix) SetOption(key, value string) error {
+	return internal.SetOption(map[string]any{
+		"mode":      &u.Mode,
+		"uid":       &u.UID,
+		"gid":       &u.GID,
+		"keep_file": &u.KeepFile,
+	}, key, value)
+}
+
+func (u Unix) mode() (fs.FileMode, error) {
+	if len(u.Mode) == 0 {
+		return 0, nil
+	}
+	m, err := strconv.ParseUint(u.Mode, 8, 32)
+	if err != nil || m > 0o777 {
+		return 0, fmt.Errorf("unix socket mode must be octal permissions, e.g. 0660")
+	}
+	return fs.FileMode(m), nil
+}
+
+func (u Unix) hasOwner() bool {
+	return len(u.Mode) > 0 || u.UID != nil || u.GID != nil
+}
+
+// Options converts the socket file config to the listen options.
+func (u Unix) Options() []Option {
+	return []Option{func(o *options) {
+		o.unix = u
+	}}
+}
+
+// IsAbstract reports whether the path is a Linux abstract socket, it starts with @.
+func IsAbstract(path string) bool {
+	return strings.HasPrefix(path, "@")
+}
+
+// newListenUnix listens the path after the check that its file is stale. The mode
+// and the owner are set to a temporary file which is linked to the path, so the
+// socket is not reachable with the default permissions.
+func newListenUnix(ctx context.Context, path string, opts []Option) (net.Listener, error) {
+	o := newOptions(opts)
+	if IsAbstract(path) {
+		return o.listen(ctx, internal.NetUNIX, path)
+	}
+	if err := o.unix.Validate(path); err != nil {
+		return nil, err
+	}
+	if err := removeStale(path); err != nil {
+		return nil, err
+	}
+
+	bind := path
+	if o.unix.hasOwner() {
+		bind = filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+"."+strconv.Itoa(os.Getpid()))
+		if err := removeStale(bind); err != nil {
+			return nil, err
+		}
+	}
+	l, err := o.listen(ctx, internal.NetUNIX, bind)
+	if err != nil {
+		return nil, err
+	}
+	ul, ok := l.(*net.UnixListener)
+	if !ok {
+		return nil, errors.Wrap(fmt.Errorf("unix listener is %T", l), l.Close())
+	}
+	ul.SetUnlinkOnClose(false)
+
+	if bind != path {
+		err = o.unix.apply(bind)
+		if err == nil {
+			err = os.Link(bind, path)
+		}
+		err = errors.Wrap(err, os.Remove(bind))
+		if err != nil {
+			return nil, errors.Wrap(fmt.Errorf("unix socket %s: %w", path, err), ul.Close())
+		}
+	}
+
+	v := &unixListener{UnixListener: ul, path: path}
+	if v.file, err = os.Stat(path); err != nil {
+		return nil, errors.Wrap(err, v.Close())
+	}
+	v.remove.Store(!o.unix.KeepFile)
+	return v, nil
+}
+
+func (u Unix) apply(path string) error {
+	mode, err := u.mode()
+	if err != nil {
+		return err
+	}
+	if len(u.Mode) > 0 {
+		if err = os.Chmod(path, mode); err != nil {
+			return err
+		}
+	}
+	if u.UID == nil && u.GID == nil {
+		return nil
+	}
+	uid, gid := -1, -1
+	if u.UID != nil {
+		uid = *u.UID
+	}
+	if u.GID != nil {
+		gid = *u.GID
+	}
+	return os.Chown(path, uid, gid)
+}
+
+// removeStale removes the socket file which is left by a stopped process,
+// the socket of a live process and a file of another type are errors.
+func removeStale(path string) error {
+	fi, err := os.Lstat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	case fi.Mode()&fs.ModeSocket == 0:
+		return fmt.Errorf("%s exists and is not a unix socket", path)
+	}
+
+	conn, err := net.DialTimeout(internal.NetUNIX, path, staleTimeout)
+	if err == nil {
+		return errors.Wrap(fmt.Errorf("unix socket %s is used by a live process", path), conn.Close())
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("check unix socket %s: %w", path, err)
+	}
+	if err = os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove stale unix socket: %w", err)
+	}
+	return nil
+}
+
+// unixListener removes its socket file on close if the file is not replaced,
+// e.g. by another process after it was removed as stale.
+type unixListener struct {
+	*net.UnixListener
+	path   string
+	file   os.FileInfo
+	remove atomic.Bool
+}
+
+// Addr is the path, the socket may be bound to a temporary name.
+func (v *unixListener) Addr() net.Addr {
+	return &net.UnixAddr{Name: v.path, Net: internal.NetUNIX}
+}
+
+func (v *unixListener) Close() error {
+	err := v.UnixListener.Close()
+	if !v.remove.Swap(false) || v.file == nil {
+		return err
+	}
+	if fi, e := os.Stat(v.path); e == nil && os.SameFile(fi, v.file) {
+		err = errors.Wrap(err, os.Remove(v.path))
+	}
+	return err
+}
@@ -0,0 +1,80 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package listen_test
+
+import (
+	"context"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"go.osspkg.com/casecheck"
+
+	"go.osspkg.com/network/listen"
+)
+
+func TestUnit_UnixFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sock")
+	gid := os.Getgid()
+	unix := listen.Unix{Mode: "0600", GID: &gid}
+
+	lis, err := listen.New(context.TODO(), "unix", path, nil, unix.Options()...)
+	casecheck.NoError(t, err)
+	l := lis.(net.Listener)
+	casecheck.Equal(t, path, l.Addr().String())
+
+	fi, err := os.Stat(path)
+	casecheck.NoError(t, err)
+	casecheck.True(t, fi.Mode()&fs.ModeSocket != 0)
+	casecheck.Equal(t, fs.FileMode(0o600), fi.Mode().Perm())
+
+	conn, err := net.Dial("unix", path)
+	casecheck.NoError(t, err)
+	casecheck.NoError(t, conn.Close())
+
+	_, err = listen.New(context.TODO(), "unix", path, nil)
+	casecheck.Error(t, err)
+
+	casecheck.NoError(t, l.Close())
+	_, err = os.Stat(path)
+	casecheck.True(t, os.IsNotExist(err))
+}
+
+func TestUnit_UnixStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sock")
+
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	casecheck.NoError(t, err)
+	stale.SetUnlinkOnClose(false)
+	casecheck.NoError(t, stale.Close())
+
+	lis, err := listen.New(context.TODO(), "unix", path, nil, listen.Unix{KeepFile: true}.Options()...)
+	casecheck.NoError(t, err)
+	casecheck.NoError(t, lis.Close())
+	_, err = os.Stat(path)
+	casecheck.NoError(t, err)
+
+	regular := filepath.Join(t.TempDir(), "file")
+	casecheck.NoError(t, os.WriteFile(regular, []byte("data"), 0o600))
+	_, err = listen.New(context.TODO(), "unix", regular, nil)
+	casecheck.Error(t, err)
+}
+
+func TestUnit_UnixAbstract(t *testing.T) {
+	path := "@network-test-" + strconv.Itoa(os.Getpid())
+	casecheck.Error(t, listen.Unix{Mode: "0600"}.Validate(path))
+
+	lis, err := listen.New(context.TODO(), "unix", path, nil)
+	casecheck.NoError(t, err)
+	defer lis.Close() // nolint: errcheck
+
+	conn, err := net.Dial("unix", path)
+	casecheck.NoError(t, err)
+	casecheck.NoError(t, conn.Close())
+}
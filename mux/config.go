@@ -0,0 +1,54 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package mux
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	initialStreamWindow uint32 = 256 * 1024
+)
+
+type Config struct {
+	AcceptBacklog     int           `yaml:"accept_backlog,omitempty"`
+	MaxStreamWindow   uint32        `yaml:"max_stream_window,omitempty"`
+	KeepAliveInterval time.Duration `yaml:"keepalive_interval,omitempty"`
+	DisableKeepAlive  bool          `yaml:"disable_keepalive,omitempty"`
+	WriteTimeout      time.Duration `yaml:"write_timeout,omitempty"`
+}
+
+func (c *Config) setDefaults() {
+	if c.AcceptBacklog == 0 {
+		c.AcceptBacklog = 256
+	}
+	if c.MaxStreamWindow == 0 {
+		c.MaxStreamWindow = initialStreamWindow
+	}
+	if c.KeepAliveInterval == 0 {
+		c.KeepAliveInterval = 30 * time.Second
+	}
+	if c.WriteTimeout == 0 {
+		c.WriteTimeout = 10 * time.Second
+	}
+}
+
+func (c Config) Validate() error {
+	if c.AcceptBacklog < 0 {
+		return fmt.Errorf("mux accept backlog must be positive")
+	}
+	if c.MaxStreamWindow != 0 && c.MaxStreamWindow < initialStreamWindow {
+		return fmt.Errorf("mux max stream window must be at least %d", initialStreamWindow)
+	}
+	if c.KeepAliveInterval < 0 {
+		return fmt.Errorf("mux keepalive interval must be positive")
+	}
+	if c.WriteTimeout < 0 {
+		return fmt.Errorf("mux write timeout must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package mux
+
+import (
+	"go.osspkg.com/errors"
+)
+
+var (
+	ErrSessionShutdown    = errors.New("mux: session shutdown")
+	ErrStreamClosed       = errors.New("mux: stream closed")
+	ErrStreamReset        = errors.New("mux: stream reset")
+	ErrStreamsExhausted   = errors.New("mux: streams exhausted")
+	ErrRemoteGoAway       = errors.New("mux: remote end is not accepting connections")
+	ErrProtocol           = errors.New("mux: protocol error")
+	ErrRecvWindowExceeded = errors.New("mux: receive window exceeded")
+	ErrKeepAliveTimeout   = errors.New("mux: keepalive timeout")
+	ErrControlOverflow    = errors.New("mux: control frame queue overflow")
+
+	ErrTimeout error = timeoutError{}
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -0,0 +1,69 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package mux
+
+import (
+	"encoding/binary"
+)
+
+// Frame header: version(1) | type(1) | flags(2) | stream id(4) | length(4)
+//
+// For data frames length is the payload size, for window updates it is the
+// window delta, for pings it is an opaque value and for go away it is a code.
+const (
+	protoVersion uint8 = 0
+	headerSize         = 12
+)
+
+const (
+	typeData uint8 = iota
+	typeWindowUpdate
+	typePing
+	typeGoAway
+)
+
+const (
+	flagSYN uint16 = 1 << iota
+	flagACK
+	flagFIN
+	flagRST
+)
+
+const (
+	goAwayNormal uint32 = iota
+	goAwayProtoErr
+	goAwayInternalErr
+)
+
+type header [headerSize]byte
+
+func (h header) Version() uint8 {
+	return h[0]
+}
+
+func (h header) Type() uint8 {
+	return h[1]
+}
+
+func (h header) Flags() uint16 {
+	return binary.BigEndian.Uint16(h[2:4])
+}
+
+func (h header) StreamID() uint32 {
+	return binary.BigEndian.Uint32(h[4:8])
+}
+
+func (h header) Length() uint32 {
+	return binary.BigEndian.Uint32(h[8:12])
+}
+
+func (h *header) encode(t uint8, flags uint16, id, length uint32) {
+	h[0] = protoVersion
+	h[1] = t
+	binary.BigEndian.PutUint16(h[2:4], flags)
+	binary.BigEndian.PutUint32(h[4:8], id)
+	binary.BigEndian.PutUint32(h[8:12], length)
+}
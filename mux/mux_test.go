@@ -0,0 +1,175 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package mux_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"go.osspkg.com/casecheck"
+
+	"go.osspkg.com/network/mux"
+)
+
+func newPair(t *testing.T, conf mux.Config) (mux.Session, mux.Session) {
+	c1, c2 := net.Pipe()
+
+	cli, err := mux.Client(c1, conf)
+	casecheck.NoError(t, err)
+	srv, err := mux.Server(c2, conf)
+	casecheck.NoError(t, err)
+
+	t.Cleanup(func() {
+		casecheck.NoError(t, cli.Close())
+		casecheck.NoError(t, srv.Close())
+	})
+	return cli, srv
+}
+
+func TestUnit_MuxStreams(t *testing.T) {
+	cli, srv := newPair(t, mux.Config{})
+	ctx := context.TODO()
+
+	go func() {
+		for {
+			s, err := srv.AcceptStream(ctx)
+			if err != nil {
+				return
+			}
+			go func() {
+				defer s.Close() // nolint: errcheck
+				io.Copy(s, s)   // nolint: errcheck
+			}()
+		}
+	}()
+
+	payload := make([]byte, 3*1024*1024)
+	_, err := rand.Read(payload)
+	casecheck.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			s, err := cli.OpenStream(ctx)
+			casecheck.NoError(t, err)
+
+			go func() {
+				_, err := s.Write(payload)
+				casecheck.NoError(t, err)
+				casecheck.NoError(t, s.CloseWrite())
+			}()
+
+			got, err := io.ReadAll(s)
+			casecheck.NoError(t, err)
+			casecheck.True(t, bytes.Equal(payload, got))
+			casecheck.NoError(t, s.Close())
+		}()
+	}
+	wg.Wait()
+
+	casecheck.Equal(t, 0, cli.NumStreams())
+}
+
+func TestUnit_MuxGoAwayAndDeadline(t *testing.T) {
+	cli, srv := newPair(t, mux.Config{KeepAliveInterval: 10 * time.Millisecond})
+	ctx := context.TODO()
+
+	rtt, err := cli.Ping()
+	casecheck.NoError(t, err)
+	casecheck.True(t, rtt > 0)
+
+	s, err := cli.OpenStream(ctx)
+	casecheck.NoError(t, err)
+	accepted, err := srv.AcceptStream(ctx)
+	casecheck.NoError(t, err)
+	casecheck.Equal(t, s.ID(), accepted.ID())
+
+	casecheck.NoError(t, s.SetReadDeadline(time.Now().Add(20*time.Millisecond)))
+	_, err = s.Read(make([]byte, 1))
+	casecheck.True(t, errors.Is(err, mux.ErrTimeout), err)
+
+	casecheck.NoError(t, srv.GoAway())
+	time.Sleep(50 * time.Millisecond)
+
+	_, err = cli.OpenStream(ctx)
+	casecheck.True(t, errors.Is(err, mux.ErrRemoteGoAway), err)
+
+	_, err = accepted.Write([]byte("still open"))
+	casecheck.NoError(t, err)
+
+	casecheck.NoError(t, srv.Close())
+	_, err = s.Read(make([]byte, 100))
+	casecheck.NoError(t, err)
+	<-cli.CloseChan()
+	_, err = s.Read(make([]byte, 1))
+	casecheck.True(t, errors.Is(err, mux.ErrSessionShutdown), err)
+}
+
+// rawFrame is a frame header of a peer which does not use the package.
+func rawFrame(t uint8, flags uint16, id, length uint32) []byte {
+	b := make([]byte, 12)
+	b[1] = t
+	binary.BigEndian.PutUint16(b[2:4], flags)
+	binary.BigEndian.PutUint32(b[4:8], id)
+	binary.BigEndian.PutUint32(b[8:12], length)
+	return b
+}
+
+func TestUnit_MuxOversizedFrame(t *testing.T) {
+	raw, c := net.Pipe()
+	defer raw.Close() // nolint: errcheck
+
+	srv, err := mux.Server(c, mux.Config{DisableKeepAlive: true})
+	casecheck.NoError(t, err)
+
+	// data of 2 GiB is announced but never sent, the session must fail without reading it
+	go raw.Write(rawFrame(0, 1, 1, 1<<31)) // nolint: errcheck
+
+	var goAway bool
+	hdr := make([]byte, 12)
+	for !goAway {
+		_, err = io.ReadFull(raw, hdr)
+		casecheck.NoError(t, err)
+		goAway = hdr[1] == 3
+	}
+	casecheck.Equal(t, uint32(1), binary.BigEndian.Uint32(hdr[8:12]))
+
+	select {
+	case <-srv.CloseChan():
+	case <-time.After(time.Second):
+		t.Fatal("session is not closed")
+	}
+}
+
+func TestUnit_MuxControlFlood(t *testing.T) {
+	raw, c := net.Pipe()
+	defer raw.Close() // nolint: errcheck
+
+	srv, err := mux.Server(c, mux.Config{DisableKeepAlive: true, WriteTimeout: 100 * time.Millisecond})
+	casecheck.NoError(t, err)
+
+	// the peer pings and never reads the acks
+	sent := 0
+	for ; sent < 100000; sent++ {
+		if _, err = raw.Write(rawFrame(2, 1, 0, uint32(sent))); err != nil {
+			break
+		}
+	}
+	casecheck.Error(t, err)
+	casecheck.True(t, sent < 2000, sent)
+	<-srv.CloseChan()
+}
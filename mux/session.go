@@ -0,0 +1,451 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package mux
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// controlBacklog bounds the control frames which wait for the writer, a peer
+// which floods pings or streams without reading overflows it.
+const controlBacklog = 1024
+
+type (
+	Session interface {
+		OpenStream(ctx context.Context) (Stream, error)
+		AcceptStream(ctx context.Context) (Stream, error)
+		Ping() (time.Duration, error)
+		GoAway() error
+		NumStreams() int
+		IsClosed() bool
+		CloseChan() <-chan struct{}
+		LocalAddr() net.Addr
+		RemoteAddr() net.Addr
+		Close() error
+	}
+
+	_session struct {
+		conf   Config
+		conn   io.ReadWriteCloser
+		client bool
+
+		nextID  uint32
+		streams map[uint32]*_stream
+		mux     sync.Mutex
+
+		acceptC chan *_stream
+
+		hdr      header
+		writeMux sync.Mutex
+		ctrlC    chan controlFrame
+
+		pingID  uint32
+		pings   map[uint32]chan struct{}
+		pingMux sync.Mutex
+
+		localGoAway  atomic.Bool
+		remoteGoAway atomic.Bool
+
+		closeC    chan struct{}
+		closeOnce sync.Once
+		closeErr  error
+	}
+
+	controlFrame struct {
+		t      uint8
+		flags  uint16
+		id     uint32
+		length uint32
+	}
+)
+
+// Client starts the session on the dialing side, its streams have odd ids.
+func Client(conn io.ReadWriteCloser, c Config) (Session, error) {
+	return newSession(conn, c, true)
+}
+
+// Server starts the session on the accepting side, its streams have even ids.
+func Server(conn io.ReadWriteCloser, c Config) (Session, error) {
+	return newSession(conn, c, false)
+}
+
+func newSession(conn io.ReadWriteCloser, c Config, client bool) (*_session, error) {
+	c.setDefaults()
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("validate config: %w", err)
+	}
+
+	v := &_session{
+		conf:    c,
+		conn:    conn,
+		nextID:  2,
+		streams: make(map[uint32]*_stream),
+		acceptC: make(chan *_stream, c.AcceptBacklog),
+		ctrlC:   make(chan controlFrame, controlBacklog),
+		pings:   make(map[uint32]chan struct{}),
+		closeC:  make(chan struct{}),
+		client:  client,
+	}
+	if client {
+		v.nextID = 1
+	}
+
+	go v.recvLoop()
+	go v.controlLoop()
+	if !c.DisableKeepAlive {
+		go v.keepalive()
+	}
+	return v, nil
+}
+
+func (v *_session) OpenStream(ctx context.Context) (Stream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if v.IsClosed() {
+		return nil, ErrSessionShutdown
+	}
+	if v.remoteGoAway.Load() {
+		return nil, ErrRemoteGoAway
+	}
+
+	v.mux.Lock()
+	id := v.nextID
+	if id >= math.MaxUint32-1 {
+		v.mux.Unlock()
+		return nil, ErrStreamsExhausted
+	}
+	v.nextID += 2
+	s := newStream(v, id)
+	v.streams[id] = s
+	v.mux.Unlock()
+
+	if err := v.writeFrame(typeWindowUpdate, flagSYN, id, v.windowDelta(), nil); err != nil {
+		v.removeStream(id)
+		return nil, err
+	}
+	return s, nil
+}
+
+func (v *_session) AcceptStream(ctx context.Context) (Stream, error) {
+	select {
+	case s := <-v.acceptC:
+		return s, nil
+	case <-v.closeC:
+		return nil, v.err()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (v *_session) Ping() (time.Duration, error) {
+	id := atomic.AddUint32(&v.pingID, 1)
+	ch := make(chan struct{})
+
+	v.pingMux.Lock()
+	v.pings[id] = ch
+	v.pingMux.Unlock()
+
+	defer func() {
+		v.pingMux.Lock()
+		delete(v.pings, id)
+		v.pingMux.Unlock()
+	}()
+
+	start := time.Now()
+	if err := v.writeFrame(typePing, flagSYN, 0, id, nil); err != nil {
+		return 0, err
+	}
+
+	timer := time.NewTimer(v.conf.WriteTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-ch:
+		return time.Since(start), nil
+	case <-timer.C:
+		return 0, ErrTimeout
+	case <-v.closeC:
+		return 0, v.err()
+	}
+}
+
+// GoAway tells the remote side that no new streams will be accepted,
+// streams that are already open keep working.
+func (v *_session) GoAway() error {
+	v.localGoAway.Store(true)
+	return v.writeFrame(typeGoAway, 0, 0, goAwayNormal, nil)
+}
+
+func (v *_session) NumStreams() int {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	return len(v.streams)
+}
+
+func (v *_session) IsClosed() bool {
+	select {
+	case <-v.closeC:
+		return true
+	default:
+		return false
+	}
+}
+
+func (v *_session) CloseChan() <-chan struct{} {
+	return v.closeC
+}
+
+func (v *_session) LocalAddr() net.Addr {
+	if c, ok := v.conn.(interface{ LocalAddr() net.Addr }); ok {
+		return c.LocalAddr()
+	}
+	return nil
+}
+
+func (v *_session) RemoteAddr() net.Addr {
+	if c, ok := v.conn.(interface{ RemoteAddr() net.Addr }); ok {
+		return c.RemoteAddr()
+	}
+	return nil
+}
+
+func (v *_session) Close() error {
+	if v.IsClosed() {
+		return nil
+	}
+	v.localGoAway.Store(true)
+	v.writeFrame(typeGoAway, 0, 0, goAwayNormal, nil) // nolint: errcheck
+	v.exit(ErrSessionShutdown)
+	return nil
+}
+
+func (v *_session) err() error {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	return v.closeErr
+}
+
+func (v *_session) exit(err error) {
+	v.closeOnce.Do(func() {
+		v.mux.Lock()
+		v.closeErr = err
+		streams := v.streams
+		v.streams = make(map[uint32]*_stream)
+		v.mux.Unlock()
+
+		close(v.closeC)
+		v.conn.Close() // nolint: errcheck
+
+		for _, s := range streams {
+			s.forceClose(ErrSessionShutdown)
+		}
+	})
+}
+
+func (v *_session) windowDelta() uint32 {
+	return v.conf.MaxStreamWindow - initialStreamWindow
+}
+
+func (v *_session) removeStream(id uint32) {
+	v.mux.Lock()
+	delete(v.streams, id)
+	v.mux.Unlock()
+}
+
+func (v *_session) writeFrame(t uint8, flags uint16, id, length uint32, body []byte) error {
+	v.writeMux.Lock()
+	defer v.writeMux.Unlock()
+
+	if v.IsClosed() {
+		return ErrSessionShutdown
+	}
+
+	if c, ok := v.conn.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		if err := c.SetWriteDeadline(time.Now().Add(v.conf.WriteTimeout)); err != nil {
+			v.exit(err)
+			return err
+		}
+	}
+
+	v.hdr.encode(t, flags, id, length)
+	if _, err := v.conn.Write(v.hdr[:]); err != nil {
+		v.exit(err)
+		return err
+	}
+	if len(body) > 0 {
+		if _, err := v.conn.Write(body); err != nil {
+			v.exit(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// writeAsync queues a control frame of the receive loop for controlLoop, so the loop
+// is not blocked by the writes, otherwise both peers can stall writing to each other.
+// ErrControlOverflow is returned if the peer does not read its control frames.
+func (v *_session) writeAsync(t uint8, flags uint16, id, length uint32) error {
+	select {
+	case v.ctrlC <- controlFrame{t: t, flags: flags, id: id, length: length}:
+		return nil
+	case <-v.closeC:
+		return ErrSessionShutdown
+	default:
+		return ErrControlOverflow
+	}
+}
+
+// controlLoop writes the queued control frames in order.
+func (v *_session) controlLoop() {
+	for {
+		select {
+		case <-v.closeC:
+			return
+		case f := <-v.ctrlC:
+			if err := v.writeFrame(f.t, f.flags, f.id, f.length, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (v *_session) recvLoop() {
+	var (
+		hdr header
+		err error
+	)
+	for {
+		if _, err = io.ReadFull(v.conn, hdr[:]); err != nil {
+			v.exit(err)
+			return
+		}
+		if hdr.Version() != protoVersion {
+			v.fail(goAwayProtoErr, ErrProtocol)
+			return
+		}
+
+		switch hdr.Type() {
+		case typeData, typeWindowUpdate:
+			err = v.handleStream(hdr)
+		case typePing:
+			err = v.handlePing(hdr)
+		case typeGoAway:
+			v.remoteGoAway.Store(true)
+		default:
+			err = ErrProtocol
+		}
+		if err != nil {
+			v.fail(goAwayProtoErr, err)
+			return
+		}
+	}
+}
+
+func (v *_session) fail(code uint32, err error) {
+	v.writeFrame(typeGoAway, 0, 0, code, nil) // nolint: errcheck
+	v.exit(err)
+}
+
+func (v *_session) handleStream(hdr header) error {
+	id, flags := hdr.StreamID(), hdr.Flags()
+
+	if flags&flagSYN != 0 {
+		if err := v.incomingStream(id); err != nil {
+			return err
+		}
+	}
+
+	v.mux.Lock()
+	s, ok := v.streams[id]
+	v.mux.Unlock()
+
+	if !ok {
+		if hdr.Type() == typeData && hdr.Length() > v.conf.MaxStreamWindow {
+			return ErrRecvWindowExceeded
+		}
+		if hdr.Type() == typeData && hdr.Length() > 0 {
+			if _, err := io.CopyN(io.Discard, v.conn, int64(hdr.Length())); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if hdr.Type() == typeWindowUpdate {
+		s.incrSendWindow(hdr.Length(), flags)
+		return nil
+	}
+	return s.readData(v.conn, hdr.Length(), flags)
+}
+
+func (v *_session) incomingStream(id uint32) error {
+	if id == 0 || (id%2 == 1) == v.client {
+		return ErrProtocol
+	}
+	if v.localGoAway.Load() {
+		return v.writeAsync(typeWindowUpdate, flagRST, id, 0)
+	}
+
+	v.mux.Lock()
+	if _, ok := v.streams[id]; ok {
+		v.mux.Unlock()
+		return ErrProtocol
+	}
+	s := newStream(v, id)
+	v.streams[id] = s
+	v.mux.Unlock()
+
+	select {
+	case v.acceptC <- s:
+		return v.writeAsync(typeWindowUpdate, flagACK, id, v.windowDelta())
+	default:
+		v.removeStream(id)
+		return v.writeAsync(typeWindowUpdate, flagRST, id, 0)
+	}
+}
+
+func (v *_session) handlePing(hdr header) error {
+	flags, id := hdr.Flags(), hdr.Length()
+
+	if flags&flagSYN != 0 {
+		return v.writeAsync(typePing, flagACK, 0, id)
+	}
+
+	v.pingMux.Lock()
+	if ch, ok := v.pings[id]; ok {
+		close(ch)
+		delete(v.pings, id)
+	}
+	v.pingMux.Unlock()
+	return nil
+}
+
+func (v *_session) keepalive() {
+	tik := time.NewTicker(v.conf.KeepAliveInterval)
+	defer tik.Stop()
+
+	for {
+		select {
+		case <-v.closeC:
+			return
+		case <-tik.C:
+			if _, err := v.Ping(); err != nil {
+				if !v.IsClosed() {
+					v.exit(ErrKeepAliveTimeout)
+				}
+				return
+			}
+		}
+	}
+}
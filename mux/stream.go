@@ -0,0 +1,317 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package mux
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	Stream interface {
+		net.Conn
+		ID() uint32
+		CloseWrite() error
+	}
+
+	_stream struct {
+		id      uint32
+		session *_session
+
+		recvBuf    bytes.Buffer
+		recvWindow uint32
+		recvCredit uint32
+		sendWindow uint32
+
+		localClosed  bool
+		remoteClosed bool
+		readClosed   bool
+		broken       error
+		mux          sync.Mutex
+
+		recvC chan struct{}
+		sendC chan struct{}
+
+		readDeadline  atomic.Value
+		writeDeadline atomic.Value
+	}
+)
+
+func newStream(s *_session, id uint32) *_stream {
+	v := &_stream{
+		id:         id,
+		session:    s,
+		recvWindow: s.conf.MaxStreamWindow,
+		sendWindow: initialStreamWindow,
+		recvC:      make(chan struct{}, 1),
+		sendC:      make(chan struct{}, 1),
+	}
+	v.readDeadline.Store(time.Time{})
+	v.writeDeadline.Store(time.Time{})
+	return v
+}
+
+func (v *_stream) ID() uint32 {
+	return v.id
+}
+
+func (v *_stream) LocalAddr() net.Addr {
+	return v.session.LocalAddr()
+}
+
+func (v *_stream) RemoteAddr() net.Addr {
+	return v.session.RemoteAddr()
+}
+
+func (v *_stream) Read(p []byte) (int, error) {
+	for {
+		v.mux.Lock()
+		if v.recvBuf.Len() > 0 {
+			n, _ := v.recvBuf.Read(p) // nolint: errcheck
+			delta := v.creditLocked(uint32(n))
+			v.mux.Unlock()
+
+			if delta > 0 {
+				if err := v.session.writeFrame(typeWindowUpdate, 0, v.id, delta, nil); err != nil {
+					return n, err
+				}
+			}
+			return n, nil
+		}
+		switch {
+		case v.broken != nil:
+			err := v.broken
+			v.mux.Unlock()
+			return 0, err
+		case v.remoteClosed || v.readClosed:
+			v.mux.Unlock()
+			return 0, io.EOF
+		}
+		v.mux.Unlock()
+
+		if err := v.wait(v.recvC, &v.readDeadline); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// creditLocked returns the window delta to send back once the consumed data
+// reaches half of the window, so the peer is not stalled on every read.
+func (v *_stream) creditLocked(n uint32) uint32 {
+	v.recvCredit += n
+	if v.recvCredit < v.session.conf.MaxStreamWindow/2 {
+		return 0
+	}
+	delta := v.recvCredit
+	v.recvCredit = 0
+	v.recvWindow += delta
+	return delta
+}
+
+func (v *_stream) Write(p []byte) (n int, err error) {
+	for n < len(p) {
+		var k int
+		k, err = v.write(p[n:])
+		n += k
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (v *_stream) write(p []byte) (int, error) {
+	for {
+		v.mux.Lock()
+		switch {
+		case v.broken != nil:
+			err := v.broken
+			v.mux.Unlock()
+			return 0, err
+		case v.localClosed:
+			v.mux.Unlock()
+			return 0, ErrStreamClosed
+		case v.sendWindow == 0:
+			v.mux.Unlock()
+			if err := v.wait(v.sendC, &v.writeDeadline); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		n := uint32(min(uint64(len(p)), uint64(v.sendWindow)))
+		v.sendWindow -= n
+		v.mux.Unlock()
+
+		if err := v.session.writeFrame(typeData, 0, v.id, n, p[:n]); err != nil {
+			return 0, err
+		}
+		return int(n), nil
+	}
+}
+
+// CloseWrite sends FIN to the peer, the stream can still be read until the peer closes it.
+func (v *_stream) CloseWrite() error {
+	v.mux.Lock()
+	if v.localClosed || v.broken != nil {
+		v.mux.Unlock()
+		return nil
+	}
+	v.localClosed = true
+	v.mux.Unlock()
+
+	notify(v.sendC)
+	err := v.session.writeFrame(typeWindowUpdate, flagFIN, v.id, 0, nil)
+	v.cleanup()
+	return err
+}
+
+func (v *_stream) Close() error {
+	v.mux.Lock()
+	v.readClosed = true
+	delta := v.creditLocked(uint32(v.recvBuf.Len()))
+	v.recvBuf.Reset()
+	v.mux.Unlock()
+
+	if delta > 0 {
+		v.session.writeFrame(typeWindowUpdate, 0, v.id, delta, nil) // nolint: errcheck
+	}
+	notify(v.recvC)
+	return v.CloseWrite()
+}
+
+func (v *_stream) SetDeadline(t time.Time) error {
+	v.readDeadline.Store(t)
+	v.writeDeadline.Store(t)
+	notify(v.recvC)
+	notify(v.sendC)
+	return nil
+}
+
+func (v *_stream) SetReadDeadline(t time.Time) error {
+	v.readDeadline.Store(t)
+	notify(v.recvC)
+	return nil
+}
+
+func (v *_stream) SetWriteDeadline(t time.Time) error {
+	v.writeDeadline.Store(t)
+	notify(v.sendC)
+	return nil
+}
+
+func (v *_stream) wait(ch chan struct{}, deadline *atomic.Value) error {
+	var timeout <-chan time.Time
+	if d, ok := deadline.Load().(time.Time); ok && !d.IsZero() {
+		dur := time.Until(d)
+		if dur <= 0 {
+			return ErrTimeout
+		}
+		timer := time.NewTimer(dur)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-ch:
+		return nil
+	case <-timeout:
+		return ErrTimeout
+	}
+}
+
+func (v *_stream) readData(r io.Reader, length uint32, flags uint16) error {
+	if length > 0 {
+		// the window is checked before the allocation, the length is set by the peer
+		v.mux.Lock()
+		if length > v.recvWindow {
+			v.mux.Unlock()
+			return ErrRecvWindowExceeded
+		}
+		v.recvWindow -= length
+		v.mux.Unlock()
+
+		b := make([]byte, length)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return err
+		}
+
+		var delta uint32
+		v.mux.Lock()
+		if v.readClosed {
+			delta = v.creditLocked(length)
+		} else {
+			v.recvBuf.Write(b) // nolint: errcheck
+		}
+		v.mux.Unlock()
+
+		if delta > 0 {
+			if err := v.session.writeAsync(typeWindowUpdate, 0, v.id, delta); err != nil {
+				return err
+			}
+		}
+		notify(v.recvC)
+	}
+
+	v.processFlags(flags)
+	return nil
+}
+
+func (v *_stream) incrSendWindow(delta uint32, flags uint16) {
+	v.processFlags(flags)
+
+	v.mux.Lock()
+	v.sendWindow += delta
+	v.mux.Unlock()
+
+	notify(v.sendC)
+}
+
+func (v *_stream) processFlags(flags uint16) {
+	switch {
+	case flags&flagRST != 0:
+		v.forceClose(ErrStreamReset)
+		v.session.removeStream(v.id)
+	case flags&flagFIN != 0:
+		v.mux.Lock()
+		v.remoteClosed = true
+		v.mux.Unlock()
+		notify(v.recvC)
+		v.cleanup()
+	}
+}
+
+func (v *_stream) forceClose(err error) {
+	v.mux.Lock()
+	if v.broken == nil {
+		v.broken = err
+	}
+	v.mux.Unlock()
+
+	notify(v.recvC)
+	notify(v.sendC)
+}
+
+func (v *_stream) cleanup() {
+	v.mux.Lock()
+	done := v.localClosed && v.remoteClosed
+	v.mux.Unlock()
+
+	if done {
+		v.session.removeStream(v.id)
+	}
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -5,16 +5,195 @@
 
 package server
 
-import "go.osspkg.com/network/listen"
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.osspkg.com/errors"
+
+	"go.osspkg.com/network/address"
+	"go.osspkg.com/network/epoll"
+	"go.osspkg.com/network/internal"
+	"go.osspkg.com/network/listen"
+	"go.osspkg.com/network/mux"
+)
+
+const (
+	// EngineGoroutine serves every connection by its own goroutine.
+	EngineGoroutine = "goroutine"
+	// EngineEpoll serves tcp, unix and udp networks by epoll loops, the handler is called
+	// per read (per frame with EpollConfig.Decoder) and the connection stays open after it.
+	EngineEpoll = "epoll"
+)
 
 type (
 	Config struct {
+		// Listener is the main address of the server, it is optional with Listeners.
+		Listener `yaml:",inline"`
+		// Listeners are served besides Address by the same handler, they start
+		// and stop together: one stopped listener shuts the server down.
+		Listeners []Listener `yaml:"listeners,omitempty"`
+		// Upgrade restarts the process without closing the listeners, see Upgrader.
+		Upgrade *UpgradeConfig `yaml:"upgrade,omitempty"`
+		// Engine is goroutine (default) or epoll. The epoll engine rejects Upgrade and
+		// the Mux, Socket, PeerPolicy, Systemd and FD options of the listeners, Unix is
+		// supported only for the unix network, the socket options are set by Epoll.
+		Engine string `yaml:"engine,omitempty"`
+		// Epoll tunes the epoll engine, the address and SSL are taken from this config.
+		Epoll *EpollConfig `yaml:"epoll,omitempty"`
+	}
+	// SSL is shared with epoll.ConfigTCP.
+	SSL = listen.SSL
+	// Socket is the socket options of a listener, see listen.Socket.
+	Socket = listen.Socket
+	// Unix is the socket file of a unix listener, see listen.Unix.
+	Unix = listen.Unix
+
+	// Listener is one address of the server.
+	Listener struct {
+		// Address is host:port or path of the Network, or an endpoint URL which sets
+		// the network too, its query sets Socket and Unix, see address.ParseEndpoint.
 		Address string `yaml:"address"`
+		// Network is tcp, tcp4, tcp6, udp, udp4, udp6, unix or quic.
 		Network string `yaml:"network"`
-		SSL     *SSL   `yaml:"ssl,omitempty"`
+		// V6Only sets IPV6_V6ONLY of the IPv6 listeners, see listen.V6Only.
+		V6Only *bool `yaml:"v6only,omitempty"`
+		SSL    *SSL  `yaml:"ssl,omitempty"`
+		// Socket tunes the listening socket: reuse port, buffers, backlog and tcp options.
+		Socket *Socket `yaml:"socket,omitempty"`
+		// Unix sets the mode and the owner of the unix socket file, see listen.Unix.
+		Unix *Unix `yaml:"unix,omitempty"`
+		// PeerPolicy allows only the listed unix peers, the others are closed
+		// before the handler. The handler gets the credentials by Peer.
+		PeerPolicy *PeerPolicy `yaml:"peer_policy,omitempty"`
+		// Systemd takes the socket of systemd socket activation with this FileDescriptorName,
+		// Address is listened if the process is not activated, see listen.Systemd.
+		Systemd string `yaml:"systemd,omitempty"`
+		// FD takes the socket inherited from the parent process by its descriptor number.
+		FD int `yaml:"fd,omitempty"`
+		// Mux enables stream multiplexing for tcp and unix networks,
+		// every logical stream is passed to the handler as a separate connection.
+		Mux *mux.Config `yaml:"mux,omitempty"`
 	}
-	SSL struct {
-		Certs      []listen.Certificate `yaml:"certs,omitempty"`
-		NextProtos []string             `yaml:"next_protos,omitempty"`
+
+	// UpgradeConfig is the restart of the process with the listener handoff.
+	UpgradeConfig struct {
+		// Signal starts the upgrade, e.g. SIGUSR2, without it the application calls Upgrade.
+		Signal string `yaml:"signal,omitempty"`
+		// Binary is the executable of the new process, the current one by default,
+		// it is started with the arguments of the current process.
+		Binary string `yaml:"binary,omitempty"`
+		// ReadyTimeout limits the start of the new process, 30s by default.
+		ReadyTimeout time.Duration `yaml:"ready_timeout,omitempty"`
+		// DrainTimeout limits the handlers of the old process after the handoff, 30s by default.
+		DrainTimeout time.Duration `yaml:"drain_timeout,omitempty"`
+	}
+
+	// EpollConfig is the subset of epoll.ConfigTCP which is not shared with Config.
+	EpollConfig struct {
+		CountEvents      uint          `yaml:"count_events,omitempty"`
+		WaitIntervalMS   uint          `yaml:"wait_interval_ms,omitempty"`
+		MaxReadBuffer    uint          `yaml:"max_read_buffer,omitempty"`
+		Loops            uint          `yaml:"loops,omitempty"`
+		Balance          string        `yaml:"balance,omitempty"`
+		Mode             string        `yaml:"mode,omitempty"`
+		Backend          string        `yaml:"backend,omitempty"`
+		WriteHighWater   uint          `yaml:"write_high_water,omitempty"`
+		MaxWriteBuffer   uint          `yaml:"max_write_buffer,omitempty"`
+		Workers          uint          `yaml:"workers,omitempty"`
+		QueueSize        uint          `yaml:"queue_size,omitempty"`
+		QueuePolicy      string        `yaml:"queue_policy,omitempty"`
+		HandshakeTimeout time.Duration `yaml:"handshake_timeout,omitempty"`
+		ReusePort        bool          `yaml:"reuse_port,omitempty"`
+		MaxPacketSize    uint          `yaml:"max_packet_size,omitempty"`
+		// Decoder splits the stream into frames, see epoll.Option.
+		Decoder epoll.Decoder `yaml:"-"`
 	}
 )
+
+// listeners returns the listener of Address, if it or an inherited socket is set
+// or no other listener is, and Listeners.
+func (c Config) listeners() []Listener {
+	list := make([]Listener, 0, len(c.Listeners)+1)
+	if len(c.Address) > 0 || len(c.Network) > 0 || len(c.Systemd) > 0 || c.FD > 0 || len(c.Listeners) == 0 {
+		list = append(list, c.Listener)
+	}
+	return append(list, c.Listeners...)
+}
+
+// endpoint applies an endpoint URL in Address and its query, a tls endpoint needs the SSL config.
+func (c *Listener) endpoint() error {
+	if !address.IsEndpoint(c.Address) {
+		return nil
+	}
+	e, err := address.ParseEndpoint(c.Address)
+	if err != nil {
+		return err
+	}
+	if len(c.Network) > 0 && c.Network != e.Network {
+		return fmt.Errorf("network %s conflicts with endpoint %s", c.Network, c.Address)
+	}
+	if e.TLS && (c.SSL == nil || len(c.SSL.Certs) == 0) {
+		return fmt.Errorf("endpoint %s needs ssl certificates, from files, data or a provider", c.Address)
+	}
+	if err = c.options(e); err != nil {
+		return fmt.Errorf("endpoint %s: %w", c.Address, err)
+	}
+	c.Network, c.Address = e.Network, e.Address()
+	return nil
+}
+
+// options sets the query of the endpoint to copies of Socket and Unix, the keys
+// of Unix are taken on the unix network. An unknown key is an error, it is not ignored.
+func (c *Listener) options(e address.Endpoint) error {
+	if len(e.Options) == 0 {
+		return nil
+	}
+	var (
+		socket Socket
+		unix   Unix
+	)
+	if c.Socket != nil {
+		socket = *c.Socket
+	}
+	if c.Unix != nil {
+		unix = *c.Unix
+	}
+	for key, values := range e.Options {
+		value := values[len(values)-1]
+		if e.Network == internal.NetUNIX {
+			err := unix.SetOption(key, value)
+			if err == nil {
+				c.Unix = &unix
+				continue
+			}
+			if !errors.Is(err, internal.ErrUnknownOption) {
+				return err
+			}
+		}
+		if err := socket.SetOption(key, value); err != nil {
+			return err
+		}
+		c.Socket = &socket
+	}
+	return nil
+}
+
+// key identifies the listener in the handoff to the new process, it is the
+// configured address, so the new process finds it by the same config.
+func (c *Listener) key() string {
+	switch {
+	case c.FD > 0:
+		return "fd:" + strconv.Itoa(c.FD)
+	case len(c.Systemd) > 0:
+		return "systemd:" + c.Systemd
+	default:
+		return c.Network + " " + c.Address
+	}
+}
+
+// inherited reports whether the socket is taken from systemd or the parent process.
+func (c *Listener) inherited() bool {
+	return len(c.Systemd) > 0 || c.FD > 0
+}
@@ -0,0 +1,213 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package server_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.osspkg.com/casecheck"
+
+	"go.osspkg.com/network/internal"
+	"go.osspkg.com/network/listen"
+	"go.osspkg.com/network/server"
+)
+
+type secrets map[string][]byte
+
+func (s secrets) Fetch(name string) ([]byte, error) {
+	b, ok := s[name]
+	if !ok {
+		return nil, fmt.Errorf("secret %s not found", name)
+	}
+	return b, nil
+}
+
+func echo(_ context.Context, w io.Writer, r io.Reader, _ net.Addr) {
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(r, b); err == nil {
+		w.Write(b) // nolint: errcheck
+	}
+}
+
+func TestUnit_EndpointOptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	addr := "tcp://" + freeAddr(t) + "?reuse_port=1&backlog=16"
+	done := make([]chan error, 2)
+	for i := range done {
+		srv := server.New(server.Config{Listener: server.Listener{Address: addr}})
+		srv.HandleFunc(echo)
+		done[i] = make(chan error, 1)
+		go func() { done[i] <- srv.ListenAndServe(ctx) }()
+	}
+	// the second server fails to bind the port without SO_REUSEPORT
+	for _, d := range done {
+		select {
+		case err := <-d:
+			t.Fatal(err)
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+	cancel()
+	for _, d := range done {
+		casecheck.NoError(t, <-d)
+	}
+
+	for _, query := range []string{"?unknown=1", "?reuse_port=maybe"} {
+		srv := server.New(server.Config{Listener: server.Listener{Address: "tcp://" + freeAddr(t) + query}})
+		srv.HandleFunc(echo)
+		err := srv.ListenAndServe(context.Background())
+		casecheck.Error(t, err)
+		casecheck.Equal(t, query == "?unknown=1", errors.Is(err, internal.ErrUnknownOption), err)
+	}
+
+	// the keys of Unix go to it on the unix network, the rest to Socket
+	path := filepath.Join(t.TempDir(), "app.sock")
+	srv := server.New(server.Config{Listener: server.Listener{Address: "unix://" + path + "?mode=0600&backlog=16"}})
+	srv.HandleFunc(echo)
+	ctx, cancel = context.WithCancel(context.Background())
+	unixDone := make(chan error)
+	go func() { unixDone <- srv.ListenAndServe(ctx) }()
+	var (
+		info os.FileInfo
+		err  error
+	)
+	for i := 0; i < 100; i++ {
+		// the mode is set after the bind
+		if info, err = os.Stat(path); err == nil && info.Mode().Perm() == 0600 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	casecheck.NoError(t, err)
+	casecheck.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	cancel()
+	casecheck.NoError(t, <-unixDone)
+}
+
+func TestUnit_EndpointTLSProvider(t *testing.T) {
+	cert, key := selfSigned(t)
+	addr := freeAddr(t)
+	srv := server.New(server.Config{Listener: server.Listener{
+		Address: "tls://" + addr,
+		SSL: &server.SSL{Certs: []listen.Certificate{{
+			CertSecret: "cert",
+			KeySecret:  "key",
+			Provider:   secrets{"cert": cert, "key": key},
+		}}},
+	}})
+	srv.HandleFunc(echo)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- srv.ListenAndServe(ctx) }()
+
+	conn := tls.Client(dial(t, addr), &tls.Config{InsecureSkipVerify: true}) // nolint: gosec
+	defer conn.Close()                                                       // nolint: errcheck
+	_, err := conn.Write([]byte("ping"))
+	casecheck.NoError(t, err)
+	b := make([]byte, 4)
+	_, err = io.ReadFull(conn, b)
+	casecheck.NoError(t, err)
+	casecheck.Equal(t, "ping", string(b))
+
+	cancel()
+	casecheck.NoError(t, <-done)
+}
+
+func selfSigned(t *testing.T) (cert, key []byte) {
+	pk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	casecheck.NoError(t, err)
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &pk.PublicKey, pk)
+	casecheck.NoError(t, err)
+	kder, err := x509.MarshalPKCS8PrivateKey(pk)
+	casecheck.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: kder})
+}
+
+func TestUnit_Listeners(t *testing.T) {
+	for _, engine := range []string{server.EngineGoroutine, server.EngineEpoll} {
+		t.Run(engine, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.sock")
+			addr := freeAddr(t)
+			conf := server.Config{
+				Listener:  server.Listener{Network: "unix", Address: path},
+				Listeners: []server.Listener{{Network: "tcp", Address: addr}},
+				Engine:    engine,
+				Epoll:     &server.EpollConfig{WaitIntervalMS: 10},
+			}
+			srv := server.New(conf)
+			srv.HandleFunc(echo)
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() { done <- srv.ListenAndServe(ctx) }()
+
+			// the handler is shared by both listeners
+			for _, conn := range []net.Conn{dial(t, addr), dialUnix(t, path)} {
+				_, err := conn.Write([]byte("ping"))
+				casecheck.NoError(t, err)
+				b := make([]byte, 4)
+				_, err = io.ReadFull(conn, b)
+				casecheck.NoError(t, err)
+				casecheck.Equal(t, "ping", string(b))
+				casecheck.NoError(t, conn.Close())
+			}
+			cancel()
+			casecheck.NoError(t, <-done)
+
+			// the listener of the busy port fails and the unix one is closed with it
+			busy, err := net.Listen("tcp", addr)
+			casecheck.NoError(t, err)
+			defer busy.Close() // nolint: errcheck
+			srv = server.New(conf)
+			srv.HandleFunc(echo)
+			casecheck.Error(t, srv.ListenAndServe(context.Background()))
+			_, err = os.Stat(path)
+			casecheck.True(t, errors.Is(err, os.ErrNotExist), err)
+		})
+	}
+}
+
+// dialUnix waits for the server to start listening.
+func dialUnix(t *testing.T, path string) net.Conn {
+	for i := 0; ; i++ {
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			return conn
+		}
+		if i == 100 {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,385 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"go.osspkg.com/errors"
+	"go.osspkg.com/syncing"
+	"go.osspkg.com/xc"
+
+	"go.osspkg.com/network/epoll"
+	"go.osspkg.com/network/internal"
+)
+
+type (
+	// _epollServer is the Server of the epoll engine, it has the same lifecycle
+	// as the goroutine engine: one run, the handler is set before it and ctx stops it.
+	_epollServer struct {
+		conf        Config
+		handlerFunc func(ctx context.Context, w io.Writer, r io.Reader, addr net.Addr)
+		sync        syncing.Switch
+		// mux guards the drainer, the servers and their connections of the run for Shutdown.
+		mux     sync.Mutex
+		drainer *drainer
+		servers []epollServer
+		conns   *epollConns
+	}
+
+	epollServer interface {
+		ListenAndServe(ctx xc.Context) error
+	}
+
+	// epollStream is a tcp or unix server, its connections are drained by Shutdown.
+	epollStream interface {
+		epollServer
+		epoll.Registry
+		StopAccept() error
+	}
+
+	// epollConns untracks the drained connections when epoll reports their close.
+	epollConns struct {
+		// once tracks the active connections before the Draining signal.
+		once    sync.Once
+		mux     sync.Mutex
+		untrack map[uint64]func()
+		// idle is closed when the last tracked connection is closed.
+		idle chan struct{}
+	}
+)
+
+var (
+	_ epollStream = (*epoll.ServerTCP)(nil)
+	_ epollStream = (*epoll.ServerUnix)(nil)
+)
+
+func newEpoll(conf Config) *_epollServer {
+	if conf.Epoll == nil {
+		conf.Epoll = &EpollConfig{}
+	}
+	return &_epollServer{
+		conf: conf,
+		sync: syncing.NewSwitch(),
+	}
+}
+
+func (v *_epollServer) HandleFunc(fn func(context.Context, io.Writer, io.Reader, net.Addr)) {
+	if v.sync.IsOn() {
+		return
+	}
+	v.handlerFunc = fn
+}
+
+// ListenAndServe runs an epoll server per listener, they share one context
+// and every server closes it on exit, so the first stopped one stops the rest.
+func (v *_epollServer) ListenAndServe(ctx context.Context) (err error) {
+	if v.handlerFunc == nil {
+		return fmt.Errorf("handler not found")
+	}
+	if v.conf.Upgrade != nil {
+		return fmt.Errorf("upgrade is not supported by the epoll engine")
+	}
+	list := v.conf.listeners()
+	for i := range list {
+		// the query of an endpoint sets the socket options, they are checked below
+		if err = list[i].endpoint(); err != nil {
+			return err
+		}
+		if list[i].Mux != nil {
+			return fmt.Errorf("mux is not supported by the epoll engine")
+		}
+		if list[i].Socket != nil {
+			return fmt.Errorf("socket options are not supported by the epoll engine, use EpollConfig")
+		}
+		if list[i].Unix != nil && list[i].Network != internal.NetUNIX {
+			return fmt.Errorf("unix socket file options are supported only for unix network")
+		}
+		if list[i].PeerPolicy != nil {
+			return fmt.Errorf("peer policy is not supported by the epoll engine")
+		}
+		if list[i].inherited() {
+			return fmt.Errorf("inherited sockets are not supported by the epoll engine")
+		}
+	}
+	d, conns := newDrainer(), &epollConns{}
+	servers := make([]epollServer, 0, len(list))
+	for _, conf := range list {
+		srv, e := v.server(conf, d, conns)
+		if e != nil {
+			return e
+		}
+		servers = append(servers, srv)
+	}
+	if !v.sync.On() {
+		return internal.ErrServAlreadyRunning
+	}
+	defer v.sync.Off()
+
+	xctx := xc.New()
+	defer close(d.finished)
+	v.mux.Lock()
+	v.drainer, v.servers, v.conns = d, servers, conns
+	v.mux.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			xctx.Close()
+		case <-xctx.Done():
+		}
+	}()
+
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		select {
+		case <-d.signal:
+			v.drain(xctx, servers, d, conns)
+			xctx.Close()
+		case <-xctx.Done():
+		}
+	}()
+
+	var (
+		wg  = syncing.NewGroup()
+		mux sync.Mutex
+	)
+	for _, srv := range servers {
+		wg.Background(func() {
+			if e := srv.ListenAndServe(xctx); e != nil {
+				mux.Lock()
+				err = errors.Wrap(err, e)
+				mux.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+	<-drained
+	return err
+}
+
+// Shutdown stops accepting and reading the datagrams, closes the Draining channel of
+// the handlers and waits until the connections are closed by the clients or the handlers
+// and the queued datagrams are handled. When ctx is done, it closes the rest, cancels
+// the packet handlers and returns ctx.Err(). A second call waits for the first one.
+func (v *_epollServer) Shutdown(ctx context.Context) (ShutdownSummary, error) {
+	v.mux.Lock()
+	d, servers, conns := v.drainer, v.servers, v.conns
+	v.mux.Unlock()
+
+	if d == nil {
+		return ShutdownSummary{}, fmt.Errorf("server is not serving")
+	}
+	select {
+	case <-d.finished:
+		return ShutdownSummary{}, fmt.Errorf("server is not serving")
+	default:
+	}
+	// the handlers get the signal after the tracking, so no handled connection is missed
+	conns.once.Do(func() {
+		conns.track(servers, d)
+		d.start(ctx)
+	})
+	<-d.finished
+	return d.result()
+}
+
+// drain waits for the tracked connections and the queued datagrams until the deadline
+// of the drain or the stop of the servers, then it closes the rest.
+func (v *_epollServer) drain(xctx xc.Context, servers []epollServer, d *drainer, conns *epollConns) {
+	d.mux.Lock()
+	deadline := d.ctx
+	d.mux.Unlock()
+
+	ctx, cancel := context.WithCancel(deadline)
+	defer cancel()
+	go func() {
+		select {
+		case <-xctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var err error
+	for _, srv := range servers {
+		if us, ok := srv.(*epoll.ServerUDP); ok && err == nil {
+			err = us.Shutdown(ctx)
+		}
+	}
+	if err == nil {
+		select {
+		case <-conns.idle:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	if err != nil {
+		d.kill()
+	}
+
+	d.mux.Lock()
+	d.err = err
+	d.mux.Unlock()
+}
+
+// track stops accepting and tracks the active connections of the servers in the drainer.
+func (c *epollConns) track(servers []epollServer, d *drainer) {
+	type active struct {
+		conn     epoll.TConnect
+		registry epoll.Registry
+	}
+	var list []active
+	for _, srv := range servers {
+		es, ok := srv.(epollStream)
+		if !ok {
+			continue
+		}
+		internal.Log("Epoll: stop accept", es.StopAccept(), nil)
+		es.Range(func(c epoll.TConnect) bool {
+			list = append(list, active{conn: c, registry: es})
+			return true
+		})
+	}
+
+	c.mux.Lock()
+	c.untrack = make(map[uint64]func(), len(list))
+	c.idle = make(chan struct{})
+	for _, a := range list {
+		c.untrack[a.conn.ID()] = d.track(a.conn)
+	}
+	if len(list) == 0 {
+		close(c.idle)
+	}
+	c.mux.Unlock()
+	// a connection closed before it is tracked is not reported again
+	for _, a := range list {
+		if _, ok := a.registry.Lookup(a.conn.ID()); !ok {
+			c.closed(a.conn)
+		}
+	}
+}
+
+// closed is epoll.Option.OnClose, the connections closed before the drain are not tracked.
+func (c *epollConns) closed(conn epoll.TConnect) {
+	c.mux.Lock()
+	untrack, ok := c.untrack[conn.ID()]
+	if ok {
+		delete(c.untrack, conn.ID())
+		if len(c.untrack) == 0 {
+			close(c.idle)
+		}
+	}
+	c.mux.Unlock()
+
+	if ok {
+		untrack()
+	}
+}
+
+// server builds the epoll server of the listener, its handlers get the Draining signal.
+func (v *_epollServer) server(conf Listener, d *drainer, conns *epollConns) (epollServer, error) {
+	handler := v.handler(d.signal)
+	ec := v.conf.Epoll
+	switch conf.Network {
+	case internal.NetTCP:
+		return &epoll.ServerTCP{
+			Handler: handler,
+			Decoder: ec.Decoder,
+			OnClose: conns.closed,
+			Config: epoll.ConfigTCP{
+				Addr:             conf.Address,
+				CountEvents:      ec.CountEvents,
+				WaitIntervalMS:   ec.WaitIntervalMS,
+				MaxReadBuffer:    ec.MaxReadBuffer,
+				Loops:            ec.Loops,
+				Balance:          ec.Balance,
+				Mode:             ec.Mode,
+				WriteHighWater:   ec.WriteHighWater,
+				MaxWriteBuffer:   ec.MaxWriteBuffer,
+				Workers:          ec.Workers,
+				QueueSize:        ec.QueueSize,
+				QueuePolicy:      ec.QueuePolicy,
+				Backend:          ec.Backend,
+				SSL:              conf.SSL,
+				HandshakeTimeout: ec.HandshakeTimeout,
+				ReusePort:        ec.ReusePort,
+			},
+		}, nil
+	case internal.NetUNIX:
+		return &epoll.ServerUnix{
+			Handler: handler,
+			Decoder: ec.Decoder,
+			OnClose: conns.closed,
+			Config: epoll.ConfigUnix{
+				Addr:           conf.Address,
+				CountEvents:    ec.CountEvents,
+				WaitIntervalMS: ec.WaitIntervalMS,
+				MaxReadBuffer:  ec.MaxReadBuffer,
+				Loops:          ec.Loops,
+				Balance:        ec.Balance,
+				Mode:           ec.Mode,
+				WriteHighWater: ec.WriteHighWater,
+				MaxWriteBuffer: ec.MaxWriteBuffer,
+				Workers:        ec.Workers,
+				QueueSize:      ec.QueueSize,
+				QueuePolicy:    ec.QueuePolicy,
+				Backend:        ec.Backend,
+				Unix:           conf.Unix,
+			},
+		}, nil
+	case internal.NetUDP:
+		return &epoll.ServerUDP{
+			Handler: packets(d, handler),
+			Config: epoll.ConfigUDP{
+				Addr:           conf.Address,
+				WaitIntervalMS: ec.WaitIntervalMS,
+				MaxPacketSize:  ec.MaxPacketSize,
+				Workers:        ec.Workers,
+				QueueSize:      ec.QueueSize,
+				QueuePolicy:    ec.QueuePolicy,
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("network %s is not supported by the epoll engine", conf.Network)
+	}
+}
+
+// handler adapts the handler to epoll, the address is taken from the writer.
+func (v *_epollServer) handler(signal chan struct{}) func(ctx context.Context, w io.Writer, r io.Reader) error {
+	return func(ctx context.Context, w io.Writer, r io.Reader) error {
+		ctx = context.WithValue(ctx, drainingKey{}, signal)
+		var addr net.Addr
+		switch c := w.(type) {
+		case interface{ RemoteAddr() net.Addr }:
+			addr = c.RemoteAddr()
+		case epoll.TConnect:
+			addr = c.Conn().RemoteAddr()
+		}
+		v.handlerFunc(ctx, w, r, addr)
+		return nil
+	}
+}
+
+// packets tracks the handlers of the datagrams in the drainer, the socket is shared,
+// so a killed handler has its context cancelled.
+func packets(d *drainer, handler func(context.Context, io.Writer, io.Reader) error) func(context.Context, io.Writer, io.Reader) error {
+	return func(ctx context.Context, w io.Writer, r io.Reader) error {
+		pctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		untrack := d.track(closerFunc(func() error {
+			cancel()
+			return nil
+		}))
+		defer untrack()
+		return handler(pctx, w, r)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"slices"
+
+	"go.osspkg.com/network/internal"
+)
+
+type (
+	// PeerCred is the process of a unix peer, it is read by SO_PEERCRED
+	// when the connection is accepted.
+	PeerCred struct {
+		PID int
+		UID int
+		GID int
+	}
+
+	// PeerPolicy allows a unix peer if its uid is in UIDs or its primary gid
+	// is in GIDs, the supplementary groups of the peer are not known.
+	PeerPolicy struct {
+		UIDs []int `yaml:"uids,omitempty"`
+		GIDs []int `yaml:"gids,omitempty"`
+	}
+)
+
+type peerCredKey struct{}
+
+// Peer returns the credentials of the unix peer of the handler context,
+// there are none for other networks and on systems without SO_PEERCRED.
+func Peer(ctx context.Context) (PeerCred, bool) {
+	cred, ok := ctx.Value(peerCredKey{}).(PeerCred)
+	return cred, ok
+}
+
+func (p PeerPolicy) Validate() error {
+	if len(p.UIDs) == 0 && len(p.GIDs) == 0 {
+		return fmt.Errorf("peer policy must allow uids or gids")
+	}
+	return nil
+}
+
+// Allow reports whether the peer passes the policy.
+func (p PeerPolicy) Allow(cred PeerCred) bool {
+	return slices.Contains(p.UIDs, cred.UID) || slices.Contains(p.GIDs, cred.GID)
+}
+
+// peerContext reads the credentials of a unix connection to the handler context
+// and checks them by the policy, other connections are passed as is. A peer
+// without the credentials is rejected if the policy is set, e.g. an inherited
+// socket which is not unix. A tls connection is checked by its socket.
+func peerContext(ctx context.Context, conn net.Conn, policy *PeerPolicy) (context.Context, error) {
+	if tc, ok := conn.(*tls.Conn); ok {
+		conn = tc.NetConn()
+	}
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		if policy != nil {
+			return nil, fmt.Errorf("peer policy needs a unix connection, got %T", conn)
+		}
+		return ctx, nil
+	}
+	rc, err := uc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	pid, uid, gid, err := internal.PeerCred(rc)
+	if err != nil {
+		if policy != nil {
+			return nil, err
+		}
+		return ctx, nil
+	}
+
+	cred := PeerCred{PID: pid, UID: uid, GID: gid}
+	if policy != nil && !policy.Allow(cred) {
+		return nil, fmt.Errorf("peer pid %d uid %d gid %d is not allowed", pid, uid, gid)
+	}
+	return context.WithValue(ctx, peerCredKey{}, cred), nil
+}
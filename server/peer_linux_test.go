@@ -0,0 +1,80 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package server_test
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"go.osspkg.com/casecheck"
+
+	"go.osspkg.com/network/listen"
+	"go.osspkg.com/network/server"
+)
+
+// inheritUnix returns a descriptor of the unix socket which is owned by nobody, as an inherited one.
+func inheritUnix(t *testing.T, path string) int {
+	l, err := net.Listen("unix", path)
+	casecheck.NoError(t, err)
+	t.Cleanup(func() { l.Close() }) // nolint: errcheck
+	f, err := l.(*net.UnixListener).File()
+	casecheck.NoError(t, err)
+	defer f.Close() // nolint: errcheck
+	fd, err := syscall.Dup(int(f.Fd()))
+	casecheck.NoError(t, err)
+	return fd
+}
+
+// TestUnit_PeerPolicyTLS checks the peer of a tls connection of an inherited
+// unix socket by the socket, a rejected peer is closed before the handshake.
+func TestUnit_PeerPolicyTLS(t *testing.T) {
+	cert, key := selfSigned(t)
+	for _, uid := range []int{os.Getuid(), os.Getuid() + 1} {
+		t.Run(strconv.Itoa(uid), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.sock")
+			srv := server.New(server.Config{Listener: server.Listener{
+				Network:    "unix",
+				FD:         inheritUnix(t, path),
+				PeerPolicy: &server.PeerPolicy{UIDs: []int{uid}},
+				SSL: &server.SSL{Certs: []listen.Certificate{{
+					CertSecret: "cert",
+					KeySecret:  "key",
+					Provider:   secrets{"cert": cert, "key": key},
+				}}},
+			}})
+			srv.HandleFunc(func(ctx context.Context, w io.Writer, _ io.Reader, _ net.Addr) {
+				cred, ok := server.Peer(ctx)
+				if ok {
+					w.Write([]byte(strconv.Itoa(cred.UID))) // nolint: errcheck
+				}
+			})
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() { done <- srv.ListenAndServe(ctx) }()
+
+			conn := tls.Client(dialUnix(t, path), &tls.Config{InsecureSkipVerify: true}) // nolint: gosec
+			b, err := io.ReadAll(conn)
+			if uid == os.Getuid() {
+				casecheck.NoError(t, err)
+				casecheck.Equal(t, strconv.Itoa(uid), string(b))
+			} else {
+				// the connection is closed before the handshake
+				casecheck.Error(t, err)
+			}
+			conn.Close() // nolint: errcheck
+
+			cancel()
+			casecheck.NoError(t, <-done)
+		})
+	}
+}
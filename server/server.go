@@ -12,33 +12,49 @@ import (
 	"io"
 	"net"
 	"os"
+	"sync"
 
 	"github.com/quic-go/quic-go"
 	"go.osspkg.com/errors"
-	"go.osspkg.com/ioutils/fs"
 	"go.osspkg.com/syncing"
 
 	"go.osspkg.com/network/address"
 	"go.osspkg.com/network/internal"
 	"go.osspkg.com/network/listen"
+	"go.osspkg.com/network/mux"
 )
 
 type (
 	Server interface {
 		HandleFunc(func(ctx context.Context, w io.Writer, r io.Reader, addr net.Addr))
 		ListenAndServe(ctx context.Context) error
+		// Shutdown stops the server which is started by ListenAndServe, see ShutdownSummary.
+		Shutdown(ctx context.Context) (ShutdownSummary, error)
 	}
 
 	_server struct {
 		conf        Config
-		listener    io.Closer
+		listeners   []*listener
 		handlerFunc func(ctx context.Context, w io.Writer, r io.Reader, addr net.Addr)
 		sync        syncing.Switch
 		wg          syncing.Group
+		// mux guards the listeners and the drainer between the start and Shutdown or Upgrade.
+		mux     sync.Mutex
+		drainer *drainer
+	}
+
+	listener struct {
+		conf   Listener
+		key    string
+		closer io.Closer
 	}
 )
 
+// New returns the Server of the conf.Engine, both engines take the same handler.
 func New(conf Config) Server {
+	if conf.Engine == EngineEpoll {
+		return newEpoll(conf)
+	}
 	return &_server{
 		conf: conf,
 		sync: syncing.NewSwitch(),
@@ -53,84 +69,205 @@ func (v *_server) HandleFunc(fn func(context.Context, io.Writer, io.Reader, net.
 	v.handlerFunc = fn
 }
 
-func (v *_server) ListenAndServe(ctx context.Context) error {
+// ListenAndServe serves all listeners until ctx is done or one of them stops,
+// then it closes the rest, cancels the handler context and waits for the handlers.
+// After Shutdown or Upgrade it returns when the handlers are drained.
+func (v *_server) ListenAndServe(ctx context.Context) (err error) {
 	if v.handlerFunc == nil {
 		return fmt.Errorf("handler not found")
 	}
+	var sig os.Signal
+	if v.conf.Upgrade != nil && len(v.conf.Upgrade.Signal) > 0 {
+		if sig, err = v.conf.Upgrade.signal(); err != nil {
+			return err
+		}
+	}
 	if !v.sync.On() {
 		return internal.ErrServAlreadyRunning
 	}
 
-	if err := v.build(ctx); err != nil {
+	d := newDrainer()
+	ctx, cancel := context.WithCancel(context.WithValue(ctx, drainingKey{}, d.signal))
+	defer func() {
+		cancel()
+		v.close()
+		v.wg.Wait()
+		close(d.finished)
+	}()
+
+	if err = v.build(ctx, d); err != nil {
 		return err
 	}
+	if sig != nil {
+		v.upgradeOnSignal(ctx, sig)
+	}
 
-	if l, ok := v.listener.(*quic.Listener); ok {
-		return v.handlingQUIC(ctx, l)
+	go func() {
+		<-ctx.Done()
+		v.close()
+	}()
+
+	var (
+		serving = syncing.NewGroup()
+		mux     sync.Mutex
+	)
+	for _, l := range v.listeners {
+		serving.Background(func() {
+			if e := v.serve(ctx, l); e != nil {
+				mux.Lock()
+				err = errors.Wrap(err, e)
+				mux.Unlock()
+			}
+			if !d.started.Load() {
+				cancel()
+			}
+		})
 	}
-	if l, ok := v.listener.(net.Listener); ok {
-		return v.handlingConn(ctx, l)
+	serving.Wait()
+
+	if d.started.Load() {
+		v.drain(ctx, d, cancel)
 	}
-	if l, ok := v.listener.(net.PacketConn); ok {
-		return v.handlingPacketConn(ctx, l)
+	return err
+}
+
+func (v *_server) serve(ctx context.Context, l *listener) error {
+	switch ln := l.closer.(type) {
+	case *quic.Listener:
+		return v.handlingQUIC(ctx, ln)
+	case net.Listener:
+		return v.handlingConn(ctx, l, ln)
+	case net.PacketConn:
+		return v.handlingPacketConn(ctx, ln)
+	default:
+		return fmt.Errorf("unknown listener")
 	}
+}
 
-	return fmt.Errorf("unknown listener")
+// stopped reports whether the listeners are closed on purpose.
+func (v *_server) stopped(ctx context.Context) bool {
+	return ctx.Err() != nil || v.drainer.started.Load()
 }
 
 func (v *_server) close() {
 	if !v.sync.Off() {
 		return
 	}
-	v.listener.Close() // nolint: errcheck
+	for _, l := range v.listeners {
+		l.closer.Close() // nolint: errcheck
+	}
 }
 
-func (v *_server) build(ctx context.Context) error {
-	switch v.conf.Network {
-	case internal.NetTCP:
-		v.conf.Address = address.ResolveIPPort(v.conf.Address)
-	case internal.NetUDP:
-		v.conf.Address = address.ResolveIPPort(v.conf.Address)
-	case internal.NetQUIC:
-		v.conf.Address = address.ResolveIPPort(v.conf.Address)
-	case internal.NetUNIX:
-		if fs.FileExist(v.conf.Address) {
-			if err := os.Remove(v.conf.Address); err != nil {
-				return errors.Wrapf(err, "fail clean socket file")
-			}
+func (v *_server) build(ctx context.Context, d *drainer) error {
+	switch v.conf.Engine {
+	case "", EngineGoroutine:
+	default:
+		return fmt.Errorf("engine must be %s or %s", EngineGoroutine, EngineEpoll)
+	}
+
+	v.mux.Lock()
+	defer v.mux.Unlock()
+
+	v.listeners = v.listeners[:0]
+	for _, conf := range v.conf.listeners() {
+		key := conf.key()
+		closer, err := v.listen(ctx, &conf, key)
+		if err != nil {
+			return fmt.Errorf("listen %s %s: %w", conf.Network, conf.Address, err)
+		}
+		v.listeners = append(v.listeners, &listener{conf: conf, key: key, closer: closer})
+	}
+	v.drainer = d
+
+	return nil
+}
+
+func (v *_server) listen(ctx context.Context, conf *Listener, key string) (io.Closer, error) {
+	if err := conf.endpoint(); err != nil {
+		return nil, err
+	}
+	if conf.Mux != nil {
+		if !internal.IsTCP(conf.Network) && conf.Network != internal.NetUNIX {
+			return nil, fmt.Errorf("mux is supported only for tcp and unix networks")
+		}
+		if err := conf.Mux.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if conf.Socket != nil {
+		if err := conf.Socket.Validate(conf.Network); err != nil {
+			return nil, err
+		}
+	}
+	if conf.Unix != nil {
+		if conf.Network != internal.NetUNIX {
+			return nil, fmt.Errorf("unix socket file options are supported only for unix network")
+		}
+		if err := conf.Unix.Validate(conf.Address); err != nil {
+			return nil, err
+		}
+	}
+	if conf.PeerPolicy != nil {
+		if conf.Network != internal.NetUNIX {
+			return nil, fmt.Errorf("peer policy is supported only for unix network")
+		}
+		if err := conf.PeerPolicy.Validate(); err != nil {
+			return nil, err
 		}
 	}
 
 	ssl := &listen.SSL{}
-	if v.conf.SSL != nil {
-		ssl.Certs = append(ssl.Certs, v.conf.SSL.Certs...)
-		ssl.NextProtos = append(ssl.NextProtos, v.conf.SSL.NextProtos...)
+	if conf.SSL != nil {
+		ssl.Certs = append(ssl.Certs, conf.SSL.Certs...)
+		ssl.NextProtos = append(ssl.NextProtos, conf.SSL.NextProtos...)
 	}
 
-	l, err := listen.New(ctx, v.conf.Network, v.conf.Address, ssl)
-	if err != nil {
-		return err
+	var opts []listen.Option
+	if conf.V6Only != nil {
+		opts = append(opts, listen.V6Only(*conf.V6Only))
+	}
+	if conf.Socket != nil {
+		opts = append(opts, conf.Socket.Options()...)
+	}
+	if conf.Unix != nil {
+		opts = append(opts, conf.Unix.Options()...)
 	}
-	v.listener = l
 
-	return nil
+	// the socket file of the address is removed by this process after the handoff
+	var path string
+	if !conf.inherited() {
+		path = conf.Address
+	}
+	if l, err := listen.Handoff(conf.Network, path, key, ssl, opts...); !errors.Is(err, listen.ErrNotInherited) {
+		return l, err
+	}
+	if conf.inherited() {
+		if conf.Socket != nil || conf.V6Only != nil || conf.Unix != nil {
+			return nil, fmt.Errorf("socket options are set by the owner of an inherited socket")
+		}
+		if conf.FD > 0 {
+			return listen.FD(conf.Network, uintptr(conf.FD), ssl)
+		}
+		l, err := listen.Systemd(conf.Network, conf.Systemd, ssl)
+		if err == nil || !errors.Is(err, listen.ErrNotInherited) || len(conf.Address) == 0 {
+			return l, err
+		}
+		// the process is not activated by systemd, the address is listened
+	}
+
+	switch {
+	case address.IsBindHost(conf.Address):
+		// an interface or a wildcard is expanded by the listener for the network family
+	case internal.IsTCP(conf.Network), internal.IsUDP(conf.Network), conf.Network == internal.NetQUIC:
+		conf.Address = address.ResolveNetIPPort(conf.Network, conf.Address)
+	}
+
+	return listen.New(ctx, conf.Network, conf.Address, ssl, opts...)
 }
 
 func (v *_server) handlingPacketConn(ctx context.Context, l net.PacketConn) error {
-	ctx, cancel := context.WithCancel(ctx)
-
 	stop := internal.DeadlineUpdate(l)
-
-	defer func() {
-		stop()
-		cancel()
-		v.wg.Wait()
-	}()
-
-	v.wg.Background(func() {
-		<-ctx.Done()
-		v.close()
-	})
+	defer stop()
 
 	buff := make([]byte, internal.UDPPacketSize)
 
@@ -143,6 +280,10 @@ func (v *_server) handlingPacketConn(ctx context.Context, l net.PacketConn) erro
 
 		n, addr, err := l.ReadFrom(buff)
 		if err != nil {
+			if v.stopped(ctx) {
+				// the listener is closed by the shutdown or the upgrade
+				return nil
+			}
 			internal.Log("PacketConn: read message", err, addr)
 			return err
 		}
@@ -154,6 +295,12 @@ func (v *_server) handlingPacketConn(ctx context.Context, l net.PacketConn) erro
 			return err
 		}
 
+		// the socket is shared, a killed handler has its context cancelled
+		pctx, cancel := context.WithCancel(ctx)
+		untrack := v.drainer.track(closerFunc(func() error {
+			cancel()
+			return nil
+		}))
 		v.wg.Background(func() {
 			defer func() {
 				if e := recover(); e != nil {
@@ -161,26 +308,16 @@ func (v *_server) handlingPacketConn(ctx context.Context, l net.PacketConn) erro
 				}
 
 				internal.DataPool.Put(req)
+				cancel()
+				untrack()
 			}()
 
-			v.handlerFunc(ctx, &internal.PacketWrite{Addr: addr, Conn: l}, req, addr)
+			v.handlerFunc(pctx, &internal.PacketWrite{Addr: addr, Conn: l}, req, addr)
 		})
 	}
 }
 
-func (v *_server) handlingConn(ctx context.Context, l net.Listener) error {
-	ctx, cancel := context.WithCancel(ctx)
-
-	v.wg.Background(func() {
-		<-ctx.Done()
-		v.close()
-	})
-
-	defer func() {
-		cancel()
-		v.wg.Wait()
-	}()
-
+func (v *_server) handlingConn(ctx context.Context, ls *listener, l net.Listener) error {
 	for {
 		select {
 		case <-ctx.Done():
@@ -190,12 +327,24 @@ func (v *_server) handlingConn(ctx context.Context, l net.Listener) error {
 
 		conn, err := l.Accept()
 		if err != nil {
+			if v.stopped(ctx) {
+				// the listener is closed by the shutdown or the upgrade
+				return nil
+			}
 			internal.Log("Conn: accept", err, nil)
 			return err
 		}
 
 		addr := conn.RemoteAddr()
 
+		// the peer is checked before the handshake of a tls connection
+		cctx, err := peerContext(ctx, conn, ls.conf.PeerPolicy)
+		if err != nil {
+			internal.Log("Conn: peer", err, addr)
+			internal.Log("Conn: close", conn.Close(), addr)
+			continue
+		}
+
 		if tc, ok := conn.(*tls.Conn); ok {
 			if err = tc.HandshakeContext(ctx); err != nil {
 				internal.Log("Conn: handshake", err, addr)
@@ -204,6 +353,14 @@ func (v *_server) handlingConn(ctx context.Context, l net.Listener) error {
 			}
 		}
 
+		if ls.conf.Mux != nil {
+			v.wg.Background(func() {
+				v.handlingMux(cctx, ls.conf.Mux, conn, addr)
+			})
+			continue
+		}
+
+		untrack := v.drainer.track(conn)
 		v.wg.Background(func() {
 			stop := internal.DeadlineUpdate(conn)
 
@@ -215,26 +372,75 @@ func (v *_server) handlingConn(ctx context.Context, l net.Listener) error {
 				stop()
 
 				internal.Log("Conn: close", conn.Close(), addr)
+				untrack()
 			}()
 
-			v.handlerFunc(ctx, conn, conn, addr)
+			v.handlerFunc(cctx, conn, conn, addr)
 		})
 	}
 }
 
-func (v *_server) handlingQUIC(ctx context.Context, l *quic.Listener) error {
-	ctx, cancel := context.WithCancel(ctx)
-
-	v.wg.Background(func() {
-		<-ctx.Done()
-		v.close()
-	})
+// handlingMux serves the streams of a session, on the drain the session sends
+// GoAway, stops accepting and is closed after its streams.
+func (v *_server) handlingMux(ctx context.Context, conf *mux.Config, conn net.Conn, addr net.Addr) {
+	sess, err := mux.Server(conn, *conf)
+	if err != nil {
+		internal.Log("Mux: session", err, addr)
+		internal.Log("Mux: close", conn.Close(), addr)
+		return
+	}
 
+	untrack := v.drainer.track(sess)
+	streams := syncing.NewGroup()
 	defer func() {
-		cancel()
-		v.wg.Wait()
+		if ctx.Err() == nil {
+			// drained or closed by the client, the streams are finished first
+			streams.Wait()
+		}
+		internal.Log("Mux: close", sess.Close(), addr)
+		streams.Wait()
+		untrack()
 	}()
 
+	actx, stopAccept := context.WithCancel(ctx)
+	defer stopAccept()
+	go func() {
+		select {
+		case <-Draining(ctx):
+			internal.Log("Mux: go away", sess.GoAway(), addr)
+			stopAccept()
+		case <-actx.Done():
+		}
+	}()
+
+	for {
+		stream, e := sess.AcceptStream(actx)
+		if e != nil {
+			if actx.Err() == nil {
+				internal.Log("Mux: accept stream", e, addr)
+			}
+			return
+		}
+
+		streams.Background(func() {
+			stop := internal.DeadlineUpdate(stream)
+
+			defer func() {
+				if e := recover(); e != nil {
+					internal.Log("Mux: panic", fmt.Errorf("%+v", e), addr)
+				}
+
+				stop()
+
+				internal.Log("Mux: close stream", stream.Close(), addr)
+			}()
+
+			v.handlerFunc(ctx, stream, stream, addr)
+		})
+	}
+}
+
+func (v *_server) handlingQUIC(ctx context.Context, l *quic.Listener) error {
 	for {
 		select {
 		case <-ctx.Done():
@@ -244,12 +450,19 @@ func (v *_server) handlingQUIC(ctx context.Context, l *quic.Listener) error {
 
 		conn, err := l.Accept(ctx)
 		if err != nil {
+			if v.stopped(ctx) {
+				// the listener is closed by the shutdown or the upgrade
+				return nil
+			}
 			internal.Log("QUIC: accept", err, nil)
 			return err
 		}
 
 		addr := conn.RemoteAddr()
 
+		untrack := v.drainer.track(closerFunc(func() error {
+			return conn.CloseWithError(0, "")
+		}))
 		v.wg.Background(func() {
 			defer func() {
 				if e := recover(); e != nil {
@@ -257,6 +470,7 @@ func (v *_server) handlingQUIC(ctx context.Context, l *quic.Listener) error {
 				}
 
 				//internal.Log("QUIC: close conn", conn.CloseWithError(0, ""), addr)
+				untrack()
 			}()
 
 			stream, e := conn.AcceptStream(ctx)
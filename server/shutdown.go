@@ -0,0 +1,187 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// ShutdownSummary counts the connections which were active when the drain started,
+// a mux session and a quic connection are counted as one connection, a udp packet
+// as one connection while its handler runs.
+type ShutdownSummary struct {
+	// Drained connections were finished by their handlers before the deadline.
+	Drained int
+	// Killed connections were closed by the server at the deadline.
+	Killed int
+}
+
+type drainingKey struct{}
+
+// Draining returns the channel which is closed when the server starts draining,
+// the handler should finish the current request and return. The handler context
+// is cancelled later, when the drain deadline is reached. The channel is nil
+// for a context which is not passed by the server.
+func Draining(ctx context.Context) <-chan struct{} {
+	c, _ := ctx.Value(drainingKey{}).(chan struct{})
+	return c
+}
+
+type (
+	// drainer tracks the connections of one run of the server and drains them,
+	// the drain is started by Shutdown or Upgrade and done by ListenAndServe.
+	drainer struct {
+		started atomic.Bool
+		// signal is closed at the start, see Draining.
+		signal chan struct{}
+		// finished is closed when ListenAndServe returns.
+		finished chan struct{}
+		ctx      context.Context
+		mux      sync.Mutex
+		conns    map[*tracked]struct{}
+		summary  ShutdownSummary
+		err      error
+	}
+
+	tracked struct {
+		closer io.Closer
+	}
+
+	closerFunc func() error
+)
+
+func (f closerFunc) Close() error { return f() }
+
+func newDrainer() *drainer {
+	return &drainer{
+		signal:   make(chan struct{}),
+		finished: make(chan struct{}),
+		conns:    make(map[*tracked]struct{}),
+	}
+}
+
+// track registers the connection, the returned func is called by its handler on exit.
+func (d *drainer) track(c io.Closer) func() {
+	t := &tracked{closer: c}
+	d.mux.Lock()
+	d.conns[t] = struct{}{}
+	d.mux.Unlock()
+
+	return func() {
+		d.mux.Lock()
+		defer d.mux.Unlock()
+		if _, ok := d.conns[t]; !ok {
+			// killed by the drain
+			return
+		}
+		delete(d.conns, t)
+		if d.started.Load() {
+			d.summary.Drained++
+		}
+	}
+}
+
+// start begins the drain with the deadline of ctx, it returns false if it is started already.
+func (d *drainer) start(ctx context.Context) bool {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if d.started.Load() {
+		return false
+	}
+	d.ctx = ctx
+	d.started.Store(true)
+	close(d.signal)
+	return true
+}
+
+// kill closes the connections which are still active, a closer may report
+// its close at once, so it is called without the lock.
+func (d *drainer) kill() {
+	d.mux.Lock()
+	list := make([]io.Closer, 0, len(d.conns))
+	for t := range d.conns {
+		list = append(list, t.closer)
+		delete(d.conns, t)
+		d.summary.Killed++
+	}
+	d.mux.Unlock()
+
+	for _, c := range list {
+		c.Close() // nolint: errcheck
+	}
+}
+
+func (d *drainer) result() (ShutdownSummary, error) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	return d.summary, d.err
+}
+
+// Shutdown stops accepting, closes the Draining channel of the handlers and waits
+// for the active connections until ctx is done, then it closes them, cancels the
+// handler context and returns ctx.Err(). ListenAndServe returns nil after it.
+func (v *_server) Shutdown(ctx context.Context) (ShutdownSummary, error) {
+	v.mux.Lock()
+	d := v.drainer
+	v.mux.Unlock()
+
+	if err := v.beginShutdown(ctx, d); err != nil {
+		return ShutdownSummary{}, err
+	}
+	<-d.finished
+	return d.result()
+}
+
+// beginShutdown starts the drain of the run and closes the listeners,
+// a second call waits for the first one.
+func (v *_server) beginShutdown(ctx context.Context, d *drainer) error {
+	if d == nil {
+		return fmt.Errorf("server is not serving")
+	}
+	select {
+	case <-d.finished:
+		return fmt.Errorf("server is not serving")
+	default:
+	}
+	if d.start(ctx) {
+		v.close()
+	}
+	return nil
+}
+
+// drain waits for the handlers until the deadline of the drain or the cancel of ctx
+// of ListenAndServe, then it kills the connections and cancels the handlers.
+func (v *_server) drain(ctx context.Context, d *drainer, cancel context.CancelFunc) {
+	idle := make(chan struct{})
+	go func() {
+		v.wg.Wait()
+		close(idle)
+	}()
+
+	d.mux.Lock()
+	deadline := d.ctx
+	d.mux.Unlock()
+
+	var err error
+	select {
+	case <-idle:
+		return
+	case <-deadline.Done():
+		err = deadline.Err()
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	d.kill()
+	cancel()
+
+	d.mux.Lock()
+	d.err = err
+	d.mux.Unlock()
+}
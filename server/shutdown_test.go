@@ -0,0 +1,230 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package server_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"go.osspkg.com/casecheck"
+
+	"go.osspkg.com/network/server"
+)
+
+func TestUnit_ShutdownEngines(t *testing.T) {
+	for _, engine := range []string{server.EngineGoroutine, server.EngineEpoll} {
+		t.Run(engine, func(t *testing.T) {
+			testShutdown(t, engine)
+		})
+	}
+}
+
+// testShutdown drains the connection waiting for the Draining signal and kills
+// the one which stays open, the handler is the same for both engines.
+func testShutdown(t *testing.T, engine string) {
+	addr := freeAddr(t)
+	srv := server.New(server.Config{
+		Listener: server.Listener{Network: "tcp", Address: addr},
+		Engine:   engine,
+		Epoll:    &server.EpollConfig{WaitIntervalMS: 10},
+	})
+
+	started := make(chan string, 2)
+	srv.HandleFunc(func(ctx context.Context, w io.Writer, r io.Reader, _ net.Addr) {
+		b := make([]byte, 4)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return
+		}
+		started <- string(b)
+		if string(b) == "wait" {
+			<-server.Draining(ctx)
+			w.Write([]byte("done")) // nolint: errcheck
+			return
+		}
+		// the goroutine engine holds the connection until it is closed
+		io.Copy(io.Discard, r) // nolint: errcheck
+	})
+
+	done := make(chan error)
+	go func() { done <- srv.ListenAndServe(context.Background()) }()
+
+	waiter, idle := dial(t, addr), dial(t, addr)
+	defer waiter.Close() // nolint: errcheck
+	defer idle.Close()   // nolint: errcheck
+	for _, c := range []net.Conn{waiter, idle} {
+		_, err := c.Write([]byte(map[net.Conn]string{waiter: "wait", idle: "idle"}[c]))
+		casecheck.NoError(t, err)
+		<-started
+	}
+
+	type result struct {
+		sum server.ShutdownSummary
+		err error
+	}
+	shut := make(chan result)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		defer cancel()
+		sum, err := srv.Shutdown(ctx)
+		shut <- result{sum: sum, err: err}
+	}()
+
+	b := make([]byte, 4)
+	_, err := io.ReadFull(waiter, b)
+	casecheck.NoError(t, err)
+	casecheck.Equal(t, "done", string(b))
+	casecheck.NoError(t, waiter.Close())
+
+	res := <-shut
+	casecheck.True(t, errors.Is(res.err, context.DeadlineExceeded), res.err)
+	casecheck.Equal(t, server.ShutdownSummary{Drained: 1, Killed: 1}, res.sum)
+	casecheck.NoError(t, <-done)
+
+	casecheck.NoError(t, idle.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err = idle.Read(b)
+	casecheck.True(t, errors.Is(err, io.EOF), err)
+
+	_, err = srv.Shutdown(context.Background())
+	casecheck.Error(t, err)
+}
+
+func TestUnit_ShutdownDrained(t *testing.T) {
+	for _, engine := range []string{server.EngineGoroutine, server.EngineEpoll} {
+		t.Run(engine, func(t *testing.T) {
+			srv := server.New(server.Config{Engine: engine})
+			_, err := srv.Shutdown(context.Background())
+			casecheck.Error(t, err)
+
+			addr := freeAddr(t)
+			srv = server.New(server.Config{
+				Listener: server.Listener{Network: "tcp", Address: addr},
+				Engine:   engine,
+				Epoll:    &server.EpollConfig{WaitIntervalMS: 10},
+			})
+			started := make(chan struct{})
+			srv.HandleFunc(func(ctx context.Context, w io.Writer, r io.Reader, _ net.Addr) {
+				b := make([]byte, 4)
+				if _, err := io.ReadFull(r, b); err != nil {
+					return
+				}
+				started <- struct{}{}
+				<-server.Draining(ctx)
+				w.Write(b) // nolint: errcheck
+			})
+			done := make(chan error)
+			go func() { done <- srv.ListenAndServe(context.Background()) }()
+
+			conn := dial(t, addr)
+			defer conn.Close() // nolint: errcheck
+			_, err = conn.Write([]byte("wait"))
+			casecheck.NoError(t, err)
+			<-started
+
+			// the second call waits for the first one and gets its result
+			results := make(chan error, 2)
+			for i := 0; i < 2; i++ {
+				go func() {
+					sum, err := srv.Shutdown(context.Background())
+					if err == nil && sum != (server.ShutdownSummary{Drained: 1}) {
+						err = fmt.Errorf("summary %+v", sum)
+					}
+					results <- err
+				}()
+			}
+			b := make([]byte, 4)
+			_, err = io.ReadFull(conn, b)
+			casecheck.NoError(t, err)
+			casecheck.NoError(t, conn.Close())
+
+			for i := 0; i < 2; i++ {
+				casecheck.NoError(t, <-results)
+			}
+			casecheck.NoError(t, <-done)
+		})
+	}
+}
+
+// TestUnit_ShutdownUDP counts the handlers of the packets as connections.
+func TestUnit_ShutdownUDP(t *testing.T) {
+	for _, engine := range []string{server.EngineGoroutine, server.EngineEpoll} {
+		t.Run(engine, func(t *testing.T) {
+			testShutdownUDP(t, engine)
+		})
+	}
+}
+
+func testShutdownUDP(t *testing.T, engine string) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	casecheck.NoError(t, err)
+	addr := pc.LocalAddr().String()
+	casecheck.NoError(t, pc.Close())
+
+	srv := server.New(server.Config{
+		Listener: server.Listener{Network: "udp", Address: addr},
+		Engine:   engine,
+		Epoll:    &server.EpollConfig{WaitIntervalMS: 10, Workers: 2},
+	})
+	started := make(chan struct{}, 2)
+	srv.HandleFunc(func(ctx context.Context, _ io.Writer, r io.Reader, _ net.Addr) {
+		b, _ := io.ReadAll(r) // nolint: errcheck
+		started <- struct{}{}
+		if string(b) == "wait" {
+			<-server.Draining(ctx)
+			return
+		}
+		<-ctx.Done()
+	})
+	done := make(chan error)
+	go func() { done <- srv.ListenAndServe(context.Background()) }()
+
+	conn, err := net.Dial("udp", addr)
+	casecheck.NoError(t, err)
+	defer conn.Close() // nolint: errcheck
+	for _, msg := range []string{"wait", "idle"} {
+		// the packets are lost or refused until the server listens
+		for sent := false; !sent; {
+			conn.Write([]byte(msg)) // nolint: errcheck
+			select {
+			case <-started:
+				sent = true
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	sum, err := srv.Shutdown(ctx)
+	casecheck.True(t, errors.Is(err, context.DeadlineExceeded), err)
+	casecheck.Equal(t, server.ShutdownSummary{Drained: 1, Killed: 1}, sum)
+	casecheck.NoError(t, <-done)
+}
+
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	casecheck.NoError(t, err)
+	defer l.Close() // nolint: errcheck
+	return l.Addr().String()
+}
+
+// dial waits for the server to start listening.
+func dial(t *testing.T, addr string) net.Conn {
+	for i := 0; ; i++ {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			return conn
+		}
+		if i == 100 {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,148 @@
+/*
+ *  Copyright (c) 2024-2025 Mikhail Knyazhev <markus621@yandex.ru>. All rights reserved.
+ *  Use of this source code is governed by a BSD 3-Clause license that can be found in the LICENSE file.
+ */
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.osspkg.com/logx"
+
+	"go.osspkg.com/network/internal"
+	"go.osspkg.com/network/listen"
+)
+
+const (
+	defaultReadyTimeout = 30 * time.Second
+	defaultDrainTimeout = 30 * time.Second
+)
+
+// Upgrader is implemented by the Server of the goroutine engine.
+type Upgrader interface {
+	// Upgrade starts the new process with the tcp, unix and udp listeners and
+	// returns when it has taken all of them, then the server is shut down as by
+	// Shutdown with UpgradeConfig.DrainTimeout and ListenAndServe returns nil.
+	// A new process which does not take some of them calls listen.HandoffReady
+	// when its listeners are built. The process keeps serving if the new one
+	// fails to start.
+	Upgrade() error
+}
+
+var upgradeSignals = map[string]os.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+}
+
+func (c UpgradeConfig) signal() (os.Signal, error) {
+	name := strings.ToUpper(c.Signal)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	sig, ok := upgradeSignals[name]
+	if !ok {
+		return nil, fmt.Errorf("upgrade signal must be SIGHUP, SIGUSR1 or SIGUSR2")
+	}
+	return sig, nil
+}
+
+func (c UpgradeConfig) withDefaults() UpgradeConfig {
+	if c.ReadyTimeout <= 0 {
+		c.ReadyTimeout = defaultReadyTimeout
+	}
+	if c.DrainTimeout <= 0 {
+		c.DrainTimeout = defaultDrainTimeout
+	}
+	return c
+}
+
+func (v *_server) Upgrade() error {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+
+	d := v.drainer
+	if !v.sync.IsOn() || d == nil || d.started.Load() {
+		return fmt.Errorf("server is not serving")
+	}
+	conf := UpgradeConfig{}
+	if v.conf.Upgrade != nil {
+		conf = *v.conf.Upgrade
+	}
+	conf = conf.withDefaults()
+
+	var (
+		keys  []string
+		files []*os.File
+	)
+	defer func() {
+		for _, f := range files {
+			f.Close() // nolint: errcheck
+		}
+	}()
+	for _, l := range v.listeners {
+		list, err := listen.Files(l.closer)
+		if err != nil {
+			return fmt.Errorf("handoff %s: %w", l.key, err)
+		}
+		for range list {
+			keys = append(keys, l.key)
+		}
+		files = append(files, list...)
+	}
+
+	binary := conf.Binary
+	if len(binary) == 0 {
+		var err error
+		if binary, err = os.Executable(); err != nil {
+			return err
+		}
+	}
+	cmd := exec.Command(binary, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := listen.StartHandoff(cmd, keys, files, conf.ReadyTimeout); err != nil {
+		return err
+	}
+	// the socket files are removed by the new process from now
+	for _, l := range v.listeners {
+		listen.HandedOff(l.closer)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), conf.DrainTimeout)
+	if err := v.beginShutdown(ctx, d); err != nil {
+		cancel()
+		return err
+	}
+	go func() {
+		defer cancel()
+		<-d.finished
+		sum, err := d.result()
+		logx.Info("Upgrade: old process is drained", "drained", sum.Drained, "killed", sum.Killed, "err", err)
+	}()
+	return nil
+}
+
+// upgradeOnSignal calls Upgrade on the signal until ctx is done.
+func (v *_server) upgradeOnSignal(ctx context.Context, sig os.Signal) {
+	sigC := make(chan os.Signal, 1)
+	signal.Notify(sigC, sig)
+	go func() {
+		defer signal.Stop(sigC)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sigC:
+				internal.Log("Upgrade", v.Upgrade(), nil)
+			}
+		}
+	}()
+}